@@ -0,0 +1,74 @@
+package kafka
+
+import (
+	"context"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+)
+
+// assign assigns the partitions of the assignment configured for the
+// consumer (at any start offsets for those partitions).
+func (c *Consumer) assign(hctx context.Context) error {
+	return c.assigned(hctx, c.config.assignment, func(tpa []kafka.TopicPartition) error {
+		return c.hooks.Assign(c.consumer, tpa)
+	})
+}
+
+// checkAssignment returns an ErrNoHandler if there is no handler (or retry
+// topic) route for the topic of any partition of an assignment, and no topic
+// pattern matches the topic.
+func checkAssignment(assignment []kafka.TopicPartition, routes map[string]route, patterns []topicPattern) error {
+	for _, tp := range assignment {
+		topic := *tp.Topic
+		if _, ok := routes[topic]; ok {
+			continue
+		}
+		matched := false
+		for _, p := range patterns {
+			if matched = p.re.MatchString(topic); matched {
+				break
+			}
+		}
+		if !matched {
+			return ErrNoHandler{Topic: topic}
+		}
+	}
+	return nil
+}
+
+// Seek resets the position of the consumer on a partition to the offset of
+// the specified TopicPartition, such that the next message consumed from the
+// partition is the message at that offset.  The partition must be assigned to
+// the consumer.
+//
+// Messages in-flight on the partition are allowed to complete and the offsets
+// of completed messages committed before the position is reset.  Messages
+// accumulated for batch handlers are discarded.  A paused partition remains
+// paused (until resumed) after the seek.
+//
+// Seek may be called from any goroutine while the consumer is running, other
+// than from a handler (the consumer waits for a handler to return, so would
+// never perform the seek).  If Run has not yet been called, Seek waits until it
+// is (or the context is cancelled).  If Run has returned, ErrConsumerNotRunning
+// is returned.
+func (c *Consumer) Seek(ctx context.Context, tp kafka.TopicPartition) error {
+	return c.do(ctx, func() error {
+		p := partitionOf(tp)
+
+		// A handler that failed while draining stops the consumer
+		if err := c.drain(map[partition]bool{p: true}); err != nil {
+			c.stopErr = err
+			return err
+		}
+
+		// The partition remains assigned, so only the state tied to its
+		// position is discarded; any pause of the partition is retained, to be
+		// resumed when it no longer applies
+		c.offsets.remove(p)
+		delete(c.rewound, p)
+		delete(c.redeliveries, p)
+
+		tp.Error = nil
+		return c.hooks.Seek(c.consumer, tp, 0)
+	})
+}
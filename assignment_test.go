@@ -0,0 +1,205 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+
+	"github.com/deltics/go-kafka/mock"
+)
+
+func TestThatTheConsumerIsAssignedPartitionsWhenConfiguredWithAnAssignment(t *testing.T) {
+	// MOCK
+	calls := []string{}
+
+	p := mock.ConsumerHooks()
+	p.Funcs().Assign = func(c *kafka.Consumer, tpa []kafka.TopicPartition) error {
+		calls = append(calls, fmt.Sprintf("assign %v", tpa))
+		return nil
+	}
+	p.Funcs().Subscribe = func(c *kafka.Consumer, ta []string, rcb kafka.RebalanceCb) error {
+		calls = append(calls, "subscribe")
+		return nil
+	}
+	p.Funcs().Unassign = func(c *kafka.Consumer) error {
+		calls = append(calls, "unassign")
+		return nil
+	}
+
+	// ARRANGE
+	cfg := NewConfig().WithHooks(p).
+		WithAssignment(partition{topic: "topicA", id: 1}.topicPartition(42)).
+		WithMessageHandler("topicA", func(ctx context.Context, msg *kafka.Message) error { return nil })
+
+	c, _ := NewConsumer(cfg)
+
+	// ACT
+	c.Run(context.Background())
+
+	// ASSERT
+	wanted := "[assign [topicA[1]@42] unassign]"
+	got := fmt.Sprintf("%v", calls)
+	if wanted != got {
+		t.Errorf("wanted %v, got %v", wanted, got)
+	}
+}
+
+func TestThatSeekCommitsCompletedMessagesAndSeeksThePartition(t *testing.T) {
+	// MOCK
+	calls := make(chan string, 10)
+
+	p := mock.ConsumerHooks()
+	p.Funcs().CommitOffset = func(c *kafka.Consumer, tpa []kafka.TopicPartition) ([]kafka.TopicPartition, error) {
+		calls <- fmt.Sprintf("commit %v", tpa)
+		return tpa, nil
+	}
+	p.Funcs().Seek = func(c *kafka.Consumer, tp kafka.TopicPartition, timeoutMs int) error {
+		calls <- fmt.Sprintf("seek %v", tp)
+		return nil
+	}
+	p.Messages([]interface{}{
+		partitionMessage("topicA", 1, 10),
+		time.Second,
+	})
+
+	// ARRANGE
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	handled := make(chan bool, 1)
+	cfg := NewConfig().WithHooks(p).
+		WithAutoCommit(false).
+		WithMessageHandler("topicA", func(ctx context.Context, msg *kafka.Message) error {
+			handled <- true
+			return nil
+		})
+
+	c, _ := NewConsumer(cfg)
+	go c.Run(ctx)
+	<-handled
+	<-calls // the commit following the handled message
+
+	// ACT
+	err := c.Seek(ctx, partition{topic: "topicA", id: 1}.topicPartition(5))
+
+	// ASSERT
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	wanted := "seek topicA[1]@5"
+	got := <-calls
+	if wanted != got {
+		t.Errorf("wanted %q, got %q", wanted, got)
+	}
+}
+
+func TestThatSeekReturnsAnErrorIfTheConsumerIsNotRunning(t *testing.T) {
+	// ARRANGE
+	cfg := NewConfig().WithHooks(mock.ConsumerHooks()).
+		WithMessageHandler("topicA", func(ctx context.Context, msg *kafka.Message) error { return nil })
+
+	c, _ := NewConsumer(cfg)
+	c.Run(context.Background())
+
+	// ACT
+	err := c.Seek(context.Background(), partition{topic: "topicA", id: 1}.topicPartition(5))
+
+	// ASSERT
+	if !errors.Is(err, ErrConsumerNotRunning) {
+		t.Errorf("wanted %v, got %v", ErrConsumerNotRunning, err)
+	}
+}
+
+func TestThatAPausedPartitionIsResumedAfterASeek(t *testing.T) {
+	// MOCK
+	calls := make(chan string, 10)
+	p := pauseHooks(calls)
+	idle := []interface{}{}
+	for i := 0; i < 100; i++ {
+		idle = append(idle, 10*time.Millisecond)
+	}
+	p.Messages(idle)
+
+	// ARRANGE
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	tp := partition{topic: "topicA", id: 0}.topicPartition(5)
+	cfg := NewConfig().WithHooks(p).
+		WithAssignment(partition{topic: "topicA", id: 0}.topicPartition(kafka.OffsetStored)).
+		WithMessageHandler("topicA", func(ctx context.Context, msg *kafka.Message) error { return nil })
+
+	c, _ := NewConsumer(cfg)
+	go c.Run(ctx)
+
+	next := func() string {
+		select {
+		case call := <-calls:
+			return call
+		case <-time.After(time.Second):
+			return "none"
+		}
+	}
+
+	// ACT
+	c.Pause("topicA")
+	got := []string{next()}
+	if err := c.Seek(ctx, tp); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	got = append(got, next())
+	c.Resume("topicA")
+	got = append(got, next())
+
+	// ASSERT
+	wanted := "[pause [topicA[0]@unset] seek topicA[0]@5 resume [topicA[0]@unset]]"
+	if got := fmt.Sprintf("%v", got); wanted != got {
+		t.Errorf("wanted %v, got %v", wanted, got)
+	}
+}
+
+func TestThatNewConsumerReturnsAnErrorForAnAssignedTopicWithNoHandler(t *testing.T) {
+	handler := func(ctx context.Context, msg *kafka.Message) error { return nil }
+
+	t.Run("topic with no handler", func(t *testing.T) {
+		// ARRANGE
+		cfg := NewConfig().WithHooks(mock.ConsumerHooks()).
+			WithAssignment(partition{topic: "topicB", id: 0}.topicPartition(kafka.OffsetStored)).
+			WithMessageHandler("topicA", handler)
+
+		// ACT
+		_, err := NewConsumer(cfg)
+
+		// ASSERT
+		wanted := ErrNoHandler{Topic: "topicB"}
+		if err != wanted {
+			t.Errorf("wanted %v, got %v", wanted, err)
+		}
+	})
+
+	t.Run("topics with handlers, retry topics and patterns", func(t *testing.T) {
+		// ARRANGE
+		cfg := NewConfig().WithHooks(mock.ConsumerHooks()).
+			WithProducerHooks(mock.ProducerHooks()).
+			WithAssignment(
+				partition{topic: "topicA", id: 0}.topicPartition(kafka.OffsetStored),
+				partition{topic: "topicA.retry", id: 0}.topicPartition(kafka.OffsetStored),
+				partition{topic: "events-1", id: 0}.topicPartition(kafka.OffsetStored),
+			).
+			WithMessageHandler("topicA", handler, RetryTopics(RetryTopic("topicA.retry", time.Minute))).
+			WithMessageHandler("^events-.*", handler)
+
+		// ACT
+		_, err := NewConsumer(cfg)
+
+		// ASSERT
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+}
@@ -0,0 +1,92 @@
+package kafka
+
+import (
+	"encoding/json"
+	"reflect"
+
+	"github.com/confluentinc/confluent-kafka-go/schemaregistry/serde/avro"
+	heetch "github.com/heetch/avro"
+)
+
+// avroRecordName returns the fully-qualified name of the record described by
+// the Avro schema of values of type T, as derived by the Avro serializer of
+// the schemaregistry package.  The name of a primitive type is the name of the
+// type (e.g. "string").
+func avroRecordName[T any]() string {
+	t := reflect.TypeOf((*T)(nil)).Elem()
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	at, err := heetch.TypeOf(reflect.Zero(t).Interface())
+	if err != nil {
+		panic("no avro schema for type: " + err.Error())
+	}
+
+	schema := []byte(at.String())
+	s := struct {
+		Name      string `json:"name"`
+		Namespace string `json:"namespace"`
+	}{}
+	if json.Unmarshal(schema, &s) != nil {
+		json.Unmarshal(schema, &s.Name)
+	}
+	if s.Namespace != "" {
+		return s.Namespace + "." + s.Name
+	}
+	return s.Name
+}
+
+// AvroSerializer returns a Serializer which encodes values using the Avro
+// serializer of the schemaregistry package (from confluent-kafka-go) with the
+// schema registry configured on the Config (see WithSchemaRegistry), in the
+// Confluent wire format.
+//
+// The schema is derived from T, which is usually a Go struct with fields
+// tagged with the names of the fields of the record.  The schema is registered
+// under the subject determined by the SubjectNameStrategy of the Config or, if
+// the Config is configured to use the latest schema (see WithUseLatestSchema),
+// values are encoded using the latest schema registered under the subject.
+//
+// AvroSerializer panics if no schema registry is configured or no Avro schema
+// can be derived from T.
+func AvroSerializer[T any](cfg *config, opts ...SchemaOption) Serializer[T] {
+	s := newSchemaSerde(cfg, opts)
+	ser, err := avro.NewGenericSerializer(s.registry, s.serdeType(), &avro.SerializerConfig{SerializerConfig: s.serializerConfig()})
+	if err != nil {
+		panic(err)
+	}
+	ser.SubjectNameStrategy = s.subjectNameStrategy(avroRecordName[T]())
+
+	return func(topic string, v T) ([]byte, error) {
+		return ser.Serialize(topic, v)
+	}
+}
+
+// AvroDeserializer returns a Deserializer which decodes values encoded using
+// an Avro schema in the Confluent wire format, using the Avro deserializer of
+// the schemaregistry package (from confluent-kafka-go) with the schema
+// registry configured on the Config (see WithSchemaRegistry).
+//
+// The schema with which a value was encoded is retrieved from the subject
+// determined by the SubjectNameStrategy of the Config, so the options must
+// match those of the Serializer (e.g. ForKeys).
+//
+// AvroDeserializer panics if no schema registry is configured or no Avro schema
+// can be derived from T.
+func AvroDeserializer[T any](cfg *config, opts ...SchemaOption) Deserializer[T] {
+	s := newSchemaSerde(cfg, opts)
+	des, err := avro.NewGenericDeserializer(s.registry, s.serdeType(), avro.NewDeserializerConfig())
+	if err != nil {
+		panic(err)
+	}
+	des.SubjectNameStrategy = s.subjectNameStrategy(avroRecordName[T]())
+
+	return func(topic string, b []byte) (T, error) {
+		var v T
+		if _, _, err := wireDecode(b); err != nil {
+			return v, err
+		}
+		err := des.DeserializeInto(topic, b, &v)
+		return v, err
+	}
+}
@@ -0,0 +1,143 @@
+package kafka
+
+import (
+	"testing"
+
+	"github.com/confluentinc/confluent-kafka-go/schemaregistry"
+
+	"github.com/deltics/go-kafka/mock"
+)
+
+type avroOrder struct {
+	Id   string  `json:"id"`
+	Note *string `json:"note"`
+}
+
+func TestThatAvroSerializedValuesAreDeserialized(t *testing.T) {
+	// ARRANGE
+	sr := mock.SchemaRegistry()
+	cfg := NewConfig().WithSchemaRegistry(sr)
+	note := "urgent"
+
+	// ACT
+	b, err := AvroSerializer[avroOrder](cfg)("orders", avroOrder{Id: "a", Note: &note})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	got, err := AvroDeserializer[avroOrder](cfg)("orders", b)
+
+	// ASSERT
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if got.Id != "a" || got.Note == nil || *got.Note != note {
+		t.Errorf("wanted order %q with note %q, got %+v", "a", note, got)
+	}
+	if id, _, _ := wireDecode(b); id != 1 {
+		t.Errorf("wanted schema id %d, got %d", 1, id)
+	}
+}
+
+func TestThatAvroSerializerRegistersSchemasUnderTheConfiguredSubject(t *testing.T) {
+	t.Run("topic name strategy (value)", func(t *testing.T) {
+		// ARRANGE
+		sr := mock.SchemaRegistry()
+		cfg := NewConfig().WithSchemaRegistry(sr)
+
+		// ACT
+		AvroSerializer[avroOrder](cfg)("orders", avroOrder{Id: "a"})
+
+		// ASSERT
+		if got, _ := sr.GetAllSubjects(); len(got) != 1 || got[0] != "orders-value" {
+			t.Errorf("wanted subject %q, got %v", "orders-value", got)
+		}
+	})
+
+	t.Run("topic name strategy (key)", func(t *testing.T) {
+		// ARRANGE
+		sr := mock.SchemaRegistry()
+		cfg := NewConfig().WithSchemaRegistry(sr)
+
+		// ACT
+		AvroSerializer[string](cfg, ForKeys())("orders", "key")
+
+		// ASSERT
+		if got, _ := sr.GetAllSubjects(); len(got) != 1 || got[0] != "orders-key" {
+			t.Errorf("wanted subject %q, got %v", "orders-key", got)
+		}
+	})
+
+	t.Run("record name strategy", func(t *testing.T) {
+		// ARRANGE
+		sr := mock.SchemaRegistry()
+		cfg := NewConfig().WithSchemaRegistry(sr).WithSubjectNameStrategy(RecordNameStrategy())
+
+		// ACT
+		AvroSerializer[avroOrder](cfg)("orders", avroOrder{Id: "a"})
+
+		// ASSERT
+		if got, _ := sr.GetAllSubjects(); len(got) != 1 || got[0] != "avroOrder" {
+			t.Errorf("wanted subject %q, got %v", "avroOrder", got)
+		}
+	})
+}
+
+func TestThatAvroSerializerReusesTheIdOfARegisteredSchema(t *testing.T) {
+	// ARRANGE
+	sr := mock.SchemaRegistry()
+	cfg := NewConfig().WithSchemaRegistry(sr)
+	serialize := AvroSerializer[avroOrder](cfg)
+
+	// ACT
+	serialize("orders", avroOrder{Id: "a"})
+	b, err := serialize("orders", avroOrder{Id: "b"})
+
+	// ASSERT
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if id, _, _ := wireDecode(b); id != 1 {
+		t.Errorf("wanted schema id %d, got %d", 1, id)
+	}
+}
+
+func TestThatAvroSerializerUsesTheLatestSchemaWhenConfigured(t *testing.T) {
+	// ARRANGE
+	sr := mock.SchemaRegistry()
+	sr.Register("orders-value", schemaregistry.SchemaInfo{Schema: `{"type":"record","name":"Order","fields":[]}`}, false)
+	latest, _ := sr.Register("orders-value", schemaregistry.SchemaInfo{Schema: `"string"`}, false)
+	cfg := NewConfig().WithSchemaRegistry(sr).WithUseLatestSchema(true)
+
+	// ACT
+	b, err := AvroSerializer[string](cfg)("orders", "value")
+
+	// ASSERT
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if id, _, _ := wireDecode(b); id != latest {
+		t.Errorf("wanted schema id %d, got %d", latest, id)
+	}
+	if versions, _ := sr.GetAllVersions("orders-value"); len(versions) != 2 {
+		t.Errorf("wanted no schema registered, got versions %v", versions)
+	}
+}
+
+func TestThatAvroDeserializerReturnsErrorForInvalidWireFormat(t *testing.T) {
+	// ACT
+	_, err := AvroDeserializer[avroOrder](NewConfig().WithSchemaRegistry(mock.SchemaRegistry()))("orders", []byte{1})
+
+	// ASSERT
+	if err != ErrInvalidWireFormat {
+		t.Errorf("wanted %v, got %v", ErrInvalidWireFormat, err)
+	}
+}
+
+func TestThatAvroSerializerPanicsForATypeWithNoAvroSchema(t *testing.T) {
+	defer func() {
+		if r := recover(); r == nil {
+			t.Error("did not panic")
+		}
+	}()
+	AvroSerializer[func()](NewConfig().WithSchemaRegistry(mock.SchemaRegistry()))
+}
@@ -0,0 +1,185 @@
+package kafka
+
+import (
+	"sync"
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+)
+
+// CommitStrategy determines when a Consumer commits the offsets of handled
+// messages (when auto-commit is disabled).  Whatever the strategy, the offsets
+// of all handled messages are committed synchronously when the Consumer stops.
+type CommitStrategy struct {
+	async    bool          // commit without waiting for the commit to complete
+	messages int           // the number of messages handled between commits
+	interval time.Duration // the time between commits
+}
+
+// CommitEachMessage returns a CommitStrategy which synchronously commits the
+// offset of each message once it has been handled.
+//
+// This is the default strategy if no other is configured.
+func CommitEachMessage() CommitStrategy {
+	return CommitStrategy{messages: 1}
+}
+
+// CommitAsync returns a CommitStrategy which commits the offset of each
+// message once it has been handled, without waiting for the commit to complete.
+// A failed commit is logged; the offset is committed again by a later commit.
+// Only one async commit is in flight at a time, so commits complete in order.
+func CommitAsync() CommitStrategy {
+	return CommitStrategy{async: true, messages: 1}
+}
+
+// CommitEvery returns a CommitStrategy which synchronously commits offsets
+// once every n messages have been handled.
+func CommitEvery(n int) CommitStrategy {
+	if n < 1 {
+		n = 1
+	}
+	return CommitStrategy{messages: n}
+}
+
+// CommitInterval returns a CommitStrategy which synchronously commits the
+// offsets of handled messages at the specified interval, whether or not
+// further messages are received.
+func CommitInterval(d time.Duration) CommitStrategy {
+	return CommitStrategy{interval: d}
+}
+
+// due returns true if offsets should be committed, given the number of
+// messages handled and the time elapsed since the last commit.
+func (s CommitStrategy) due(handled int, elapsed time.Duration) bool {
+	if s.interval > 0 {
+		return elapsed >= s.interval
+	}
+	return handled >= s.messages
+}
+
+// asyncCommits are the offsets to be committed asynchronously.  Only one async
+// commit is in flight at any time; offsets to be committed while a commit is in
+// flight are held until it completes, keeping only the latest offset for each
+// partition.  Commits are therefore made in order, and a commit that completes
+// late cannot move the committed offset of a partition backwards.
+type asyncCommits struct {
+	mu       sync.Mutex
+	pending  map[partition]kafka.TopicPartition // offsets to be committed once the commit in flight has completed
+	inFlight bool                               // true if a commit is in flight
+	wg       sync.WaitGroup                     // done when no commit is in flight
+}
+
+// commit commits the offsets of completed messages according to the commit
+// strategy (unless auto-commit is enabled).
+func (c *Consumer) commit() error {
+	if c.autoCommit {
+		return nil
+	}
+
+	now := c.now()
+	if !c.commitStrategy.due(c.handled, now.Sub(c.lastCommit)) {
+		return nil
+	}
+
+	tpa := c.offsets.uncommitted()
+	if len(tpa) == 0 {
+		return nil
+	}
+	c.handled = 0
+	c.lastCommit = now
+
+	if c.commitStrategy.async {
+		c.commitAsync(tpa)
+		return nil
+	}
+	return c.commitSync(tpa)
+}
+
+// commitAll synchronously commits the offsets of all completed messages
+// (unless auto-commit is enabled), first waiting for any async commits to
+// complete.  If async commits have been made, the offsets for all partitions
+// are committed, in case any of those commits failed.
+func (c *Consumer) commitAll() error {
+	if c.autoCommit {
+		return nil
+	}
+
+	c.asyncCommits.wg.Wait()
+
+	tpa := c.offsets.uncommitted()
+	if c.commitStrategy.async {
+		tpa = c.offsets.all()
+	}
+	if len(tpa) == 0 {
+		return nil
+	}
+	return c.commitSync(tpa)
+}
+
+// commitSync commits the specified offsets, waiting for the commit to complete.
+// The offsets of an exactly-once consumer are committed in a transaction.
+func (c *Consumer) commitSync(tpa []kafka.TopicPartition) error {
+	if c.transactions != nil {
+		return c.commitTransaction(tpa)
+	}
+	if _, err := c.hooks.CommitOffset(c.consumer, tpa); err != nil {
+		return err
+	}
+	c.offsets.committed(tpa)
+	return nil
+}
+
+// commitAsync commits the specified offsets without waiting for the commit to
+// complete.  The offsets are recorded as committed immediately; if the commit
+// fails the error is logged.  If a commit is already in flight the offsets are
+// committed once it has completed (see asyncCommits).
+func (c *Consumer) commitAsync(tpa []kafka.TopicPartition) {
+	c.offsets.committed(tpa)
+
+	ac := &c.asyncCommits
+	ac.mu.Lock()
+	if ac.pending == nil {
+		ac.pending = map[partition]kafka.TopicPartition{}
+	}
+	for _, tp := range tpa {
+		p := partitionOf(tp)
+		if pending, ok := ac.pending[p]; !ok || tp.Offset > pending.Offset {
+			ac.pending[p] = tp
+		}
+	}
+	if ac.inFlight {
+		ac.mu.Unlock()
+		return
+	}
+	ac.inFlight = true
+	ac.wg.Add(1)
+	ac.mu.Unlock()
+
+	c.commitPending()
+}
+
+// commitPending commits any offsets held while a previous async commit was in
+// flight, or records that no commit is in flight if there are none.
+func (c *Consumer) commitPending() {
+	ac := &c.asyncCommits
+	ac.mu.Lock()
+	if len(ac.pending) == 0 {
+		ac.inFlight = false
+		ac.mu.Unlock()
+		ac.wg.Done()
+		return
+	}
+	tpa := make([]kafka.TopicPartition, 0, len(ac.pending))
+	for _, tp := range ac.pending {
+		tpa = append(tpa, tp)
+	}
+	ac.pending = map[partition]kafka.TopicPartition{}
+	ac.mu.Unlock()
+
+	c.hooks.CommitOffsetAsync(c.consumer, tpa, func(_ []kafka.TopicPartition, err error) {
+		if err != nil {
+			c.logger.Printf("async commit of offsets %v failed: %v", tpa, err)
+		}
+		c.commitPending()
+	})
+}
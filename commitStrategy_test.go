@@ -0,0 +1,236 @@
+package kafka
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+
+	"github.com/deltics/go-kafka/mock"
+)
+
+// commitTestConsumer returns a Consumer which handles n messages on a topic
+// using the specified commit strategy, recording the offsets committed
+// synchronously and asynchronously.
+func commitTestConsumer(n int, s CommitStrategy, sync *[]kafka.Offset, async *[]kafka.Offset) *Consumer {
+	p := mock.ConsumerHooks()
+	p.Funcs().CommitOffset = func(c *kafka.Consumer, tpa []kafka.TopicPartition) ([]kafka.TopicPartition, error) {
+		for _, tp := range tpa {
+			*sync = append(*sync, tp.Offset)
+		}
+		return tpa, nil
+	}
+	p.Funcs().CommitOffsetAsync = func(c *kafka.Consumer, tpa []kafka.TopicPartition, fn func([]kafka.TopicPartition, error)) {
+		for _, tp := range tpa {
+			*async = append(*async, tp.Offset)
+		}
+		fn(tpa, nil)
+	}
+	p.Messages(batchMessages("topicA", n))
+
+	cfg := NewConfig().WithHooks(p).
+		WithAutoCommit(false).
+		WithCommitStrategy(s).
+		WithMessageHandler("topicA", func(ctx context.Context, msg *kafka.Message) error {
+			return nil
+		})
+
+	c, _ := NewConsumer(cfg)
+	return c
+}
+
+// fakeClock is a clock which advances only when told to.
+type fakeClock struct {
+	mu sync.Mutex
+	t  time.Time
+}
+
+func (c *fakeClock) now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.t
+}
+
+func (c *fakeClock) advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.t = c.t.Add(d)
+}
+
+func TestThatOffsetsAreCommittedForEachMessageByDefault(t *testing.T) {
+	// ARRANGE
+	sync, async := []kafka.Offset{}, []kafka.Offset{}
+	c := commitTestConsumer(3, CommitStrategy{}, &sync, &async)
+
+	// ACT
+	c.Run(context.Background())
+
+	// ASSERT
+	wanted := "[1 2 3] []"
+	got := fmt.Sprintf("%v %v", sync, async)
+	if wanted != got {
+		t.Errorf("wanted %v, got %v", wanted, got)
+	}
+}
+
+func TestThatOffsetsAreCommittedAsynchronouslyWithCommitAsync(t *testing.T) {
+	// ARRANGE
+	sync, async := []kafka.Offset{}, []kafka.Offset{}
+	c := commitTestConsumer(3, CommitAsync(), &sync, &async)
+
+	// ACT
+	c.Run(context.Background())
+
+	// ASSERT
+	wanted := "[3] [1 2 3]"
+	got := fmt.Sprintf("%v %v", sync, async)
+	if wanted != got {
+		t.Errorf("wanted %v, got %v", wanted, got)
+	}
+}
+
+func TestThatOffsetsAreCommittedEveryNMessagesWithCommitEvery(t *testing.T) {
+	// ARRANGE
+	sync, async := []kafka.Offset{}, []kafka.Offset{}
+	c := commitTestConsumer(7, CommitEvery(3), &sync, &async)
+
+	// ACT
+	c.Run(context.Background())
+
+	// ASSERT
+	wanted := "[3 6 7] []"
+	got := fmt.Sprintf("%v %v", sync, async)
+	if wanted != got {
+		t.Errorf("wanted %v, got %v", wanted, got)
+	}
+}
+
+func TestThatOffsetsAreCommittedAtIntervalsWithCommitInterval(t *testing.T) {
+	// ARRANGE
+	sync, async := []kafka.Offset{}, []kafka.Offset{}
+	c := commitTestConsumer(5, CommitInterval(time.Hour), &sync, &async)
+
+	// ACT
+	c.Run(context.Background())
+
+	// ASSERT
+	wanted := "[5] []"
+	got := fmt.Sprintf("%v %v", sync, async)
+	if wanted != got {
+		t.Errorf("wanted %v, got %v", wanted, got)
+	}
+}
+
+func TestThatAsyncCommitsAreCommittedInOrder(t *testing.T) {
+	// MOCK
+	mu := sync.Mutex{}
+	committed := []kafka.Offset{}
+
+	// Each commit completes sooner than the one before, so that commits made
+	// concurrently would complete out of order
+	delay := 30 * time.Millisecond
+
+	p := mock.ConsumerHooks()
+	p.Funcs().CommitOffsetAsync = func(c *kafka.Consumer, tpa []kafka.TopicPartition, fn func([]kafka.TopicPartition, error)) {
+		d := delay
+		delay -= 10 * time.Millisecond
+		go func() {
+			time.Sleep(d)
+			mu.Lock()
+			for _, tp := range tpa {
+				committed = append(committed, tp.Offset)
+			}
+			mu.Unlock()
+			fn(tpa, nil)
+		}()
+	}
+	p.Messages(batchMessages("topicA", 3))
+
+	// ARRANGE
+	cfg := NewConfig().WithHooks(p).
+		WithAutoCommit(false).
+		WithCommitStrategy(CommitAsync()).
+		WithMessageHandler("topicA", func(ctx context.Context, msg *kafka.Message) error {
+			return nil
+		})
+
+	c, _ := NewConsumer(cfg)
+
+	// ACT
+	c.Run(context.Background())
+
+	// ASSERT
+	mu.Lock()
+	defer mu.Unlock()
+
+	for i := 1; i < len(committed); i++ {
+		if committed[i] < committed[i-1] {
+			t.Fatalf("committed offset moved backwards: %v", committed)
+		}
+	}
+	if n := len(committed); n == 0 || committed[n-1] != 3 {
+		t.Errorf("wanted offset %d committed last, got %v", 3, committed)
+	}
+}
+
+func TestThatOffsetsAreCommittedWhenTheCommitIntervalElapsesWhileIdle(t *testing.T) {
+	// MOCK
+	committed := make(chan []kafka.TopicPartition, 10)
+
+	p := mock.ConsumerHooks()
+	p.Funcs().CommitOffset = func(c *kafka.Consumer, tpa []kafka.TopicPartition) ([]kafka.TopicPartition, error) {
+		committed <- tpa
+		return tpa, nil
+	}
+	idle := []interface{}{}
+	for i := 0; i < 100; i++ {
+		idle = append(idle, 10*time.Millisecond)
+	}
+	p.Messages(append(batchMessages("topicA", 1), idle...))
+
+	// ARRANGE
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	handled := make(chan bool, 1)
+	cfg := NewConfig().WithHooks(p).
+		WithAutoCommit(false).
+		WithCommitStrategy(CommitInterval(time.Minute)).
+		WithMessageHandler("topicA", func(ctx context.Context, msg *kafka.Message) error {
+			handled <- true
+			return nil
+		})
+
+	clock := &fakeClock{t: time.Now()}
+	c, _ := NewConsumer(cfg)
+	c.now = clock.now
+
+	result := make(chan error, 1)
+	go func() { result <- c.Run(ctx) }()
+	defer func() { cancel(); <-result }()
+
+	<-handled
+	time.Sleep(20 * time.Millisecond)
+
+	select {
+	case tpa := <-committed:
+		t.Fatalf("offsets %v committed before the commit interval elapsed", tpa)
+	default:
+	}
+
+	// ACT
+	clock.advance(time.Minute)
+
+	// ASSERT
+	select {
+	case tpa := <-committed:
+		if len(tpa) != 1 || tpa[0].Offset != 1 {
+			t.Errorf("wanted offset %d committed, got %v", 1, tpa)
+		}
+	case <-time.After(time.Second):
+		t.Error("offsets were not committed when the commit interval elapsed")
+	}
+}
@@ -4,8 +4,10 @@ import (
 	"context"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/confluentinc/confluent-kafka-go/kafka"
+	"github.com/confluentinc/confluent-kafka-go/schemaregistry"
 
 	_hooks "github.com/deltics/go-kafka/hooks"
 )
@@ -13,27 +15,94 @@ import (
 type MessageMiddleware func(*kafka.Message) (*kafka.Message, error)
 type MessageHandler func(context.Context, *kafka.Message) error
 
+// BatchMessageHandler is called with a batch of messages received on a topic.
+// The messages in a batch are in the order in which they were received.
+type BatchMessageHandler func(context.Context, []*kafka.Message) error
+
+// TransactionalHandler is called with a message received on a topic by an
+// exactly-once Consumer, together with the Transaction in which the message is
+// handled.  Messages produced using the Transaction are delivered only if the
+// message is committed as consumed.
+type TransactionalHandler func(context.Context, *kafka.Message, *Transaction) error
+
+// Ordering identifies the order in which messages are handled by a Consumer
+// configured for concurrency.
+type Ordering int
+
+const (
+	// PartitionOrder handles messages from each partition in order.  Messages
+	// from different partitions may be handled concurrently.
+	PartitionOrder Ordering = iota
+
+	// KeyOrder handles messages with the same key on each partition in order.
+	// Messages with different keys may be handled concurrently, even if on the
+	// same partition.
+	KeyOrder
+)
+
 type config struct {
-	hooks      interface{}
-	config     configMap
-	middleware MessageMiddleware
+	hooks         interface{}
+	producerHooks _hooks.ProducerHooks // hooks for producers, where hooks are ConsumerHooks
+	config        configMap
+	middleware    middlewareChain
+	// Producer-only members
+	deliveryTimeout time.Duration // maximum time to wait for a delivery event for a message
 	// Consumer-only members
-	messageHandlers messageHandlerMap // map of topic-name:handler
+	messageHandlers messageHandlerMap      // map of topic-name:handler
+	drainTimeout    time.Duration          // time allowed for an in-flight handler to complete when stopping
+	concurrency     int                    // number of messages that may be handled concurrently
+	ordering        Ordering               // order in which messages are handled when concurrent
+	failurePolicy   FailurePolicy          // default policy for handler failures
+	commitStrategy  CommitStrategy         // determines when offsets are committed
+	onAssigned      PartitionsFunc         // called when partitions are assigned
+	onRevoked       PartitionsFunc         // called when partitions are revoked
+	backpressure    int                    // number of in-flight messages at which a partition is paused
+	assignment      []kafka.TopicPartition // partitions to be assigned (instead of subscribing to topics)
+	startFrom       startPositionMap       // positions from which topics are consumed ("" for all topics)
+	deadLetter      DeadLetterFunc         // called for messages sent to the dead-letter by a failure policy
+	deadLetterTopic string                 // topic to which messages are sent by the dead-letter
+	logger          Logger
+	repanic         bool // re-panic after logging a recovered handler panic
+	// Schema registry serdes
+	schemaRegistry      schemaregistry.Client // registry for the schemas of schema registry serdes
+	subjectNameStrategy SubjectNameStrategy   // determines the subjects under which schemas are registered
+	useLatestSchema     bool                  // encode values using the latest schema for a subject, rather than registering schemas
 }
 
 func NewConfig() *config {
 	return &config{
 		config:          configMap{},
 		messageHandlers: messageHandlerMap{},
+		startFrom:       startPositionMap{},
 	}
 }
 
 func (c *config) copy() *config {
 	return &config{
 		hooks:           c.hooks,
-		middleware:      c.middleware,
+		producerHooks:   c.producerHooks,
+		middleware:      c.middleware.copy(),
+		deliveryTimeout: c.deliveryTimeout,
 		config:          c.config.copy(),
 		messageHandlers: c.messageHandlers.copy(),
+		drainTimeout:    c.drainTimeout,
+		concurrency:     c.concurrency,
+		ordering:        c.ordering,
+		failurePolicy:   c.failurePolicy,
+		commitStrategy:  c.commitStrategy,
+		onAssigned:      c.onAssigned,
+		onRevoked:       c.onRevoked,
+		backpressure:    c.backpressure,
+		assignment:      c.assignment,
+		startFrom:       c.startFrom.copy(),
+		deadLetter:      c.deadLetter,
+		deadLetterTopic: c.deadLetterTopic,
+		logger:          c.logger,
+		repanic:         c.repanic,
+
+		schemaRegistry:      c.schemaRegistry,
+		subjectNameStrategy: c.subjectNameStrategy,
+		useLatestSchema:     c.useLatestSchema,
 	}
 }
 
@@ -42,18 +111,88 @@ func (c *config) autoCommit() bool {
 	return !ok || enabled.(bool)
 }
 
+func (c *config) groupId() string {
+	id, _ := c.config[key[groupId]].(string)
+	return id
+}
+
+func (c *config) transactionalId() string {
+	id, _ := c.config[key[transactionalId]].(string)
+	return id
+}
+
 func (c *config) With(key string, value interface{}) *config {
 	r := c.copy()
 	r.config[key] = value
 	return r
 }
 
+// WithAssignment returns a Config for a Consumer which is assigned the
+// specified partitions rather than subscribing to the topics for which
+// handlers are configured.  The consumer does not join the consumer group, so
+// no partitions are revoked from or assigned to it by a rebalance.
+//
+// The Offset of each partition determines the position from which the
+// partition is consumed: a specific offset, kafka.OffsetBeginning,
+// kafka.OffsetEnd or kafka.OffsetStored (the offset committed for the
+// consumer group).  A handler must be configured for the topic of each
+// partition (or a pattern matching the topic); otherwise NewConsumer returns
+// an ErrNoHandler.
+func (c *config) WithAssignment(partitions ...kafka.TopicPartition) *config {
+	r := c.copy()
+	r.assignment = append([]kafka.TopicPartition{}, partitions...)
+	return r
+}
+
 func (c *config) WithAutoCommit(v bool) *config {
 	r := c.copy()
 	r.config[key[enableAutoCommit]] = v
 	return r
 }
 
+// WithBackpressure returns a Config with which a Consumer pauses a partition
+// when the number of messages from that partition dispatched to handlers but
+// not yet completed reaches the specified threshold.  The partition is resumed
+// once the number of in-flight messages has fallen to half the threshold.
+//
+// Backpressure applies only where messages from a partition may be handled
+// concurrently (see WithConcurrency and WithOrdering) and keeps the consumer
+// polling (and so in the consumer group) while handlers catch up.
+func (c *config) WithBackpressure(threshold int) *config {
+	r := c.copy()
+	r.backpressure = threshold
+	return r
+}
+
+// WithBatchMessageHandler returns a Config with a BatchMessageHandler for the
+// specified topic (or topic pattern; see WithMessageHandler).  Messages are
+// accumulated until there are maxSize messages or maxWait has elapsed since
+// the first message in the batch was received, whichever is sooner, and the
+// handler is then called once with the batch.
+//
+// The offsets of messages in a batch are committed only once the batch has
+// been handled successfully (or the failure of the batch dealt with by the
+// FailurePolicy).  A batch that is incomplete when the consumer stops is not
+// handled; its messages will be received again when the consumer is restarted.
+//
+// A FailurePolicy is applied to a batch as a whole, with Failure.Batch holding
+// the messages in the batch.  Messages sent to a dead-letter or retry topic are
+// sent individually.
+func (c *config) WithBatchMessageHandler(t string, fn BatchMessageHandler, maxSize int, maxWait time.Duration, opts ...HandlerOption) *config {
+	if maxSize < 1 {
+		panic(fmt.Sprintf("invalid batch size (%d): must be at least 1", maxSize))
+	}
+	r := c.copy()
+
+	h := messageHandler{batchFn: fn, batchSize: maxSize, batchWait: maxWait}
+	for _, opt := range opts {
+		opt(&h)
+	}
+	r.messageHandlers[t] = h
+
+	return r
+}
+
 func (c *config) WithBatchSize(size int) *config {
 	r := c.copy()
 	r.config[key[batchSize]] = size
@@ -75,6 +214,94 @@ func (c *config) WithBootstrapServers(servers interface{}) *config {
 	return r
 }
 
+// WithCommitStrategy returns a Config with the CommitStrategy determining when
+// a Consumer commits offsets (when auto-commit is disabled).
+func (c *config) WithCommitStrategy(s CommitStrategy) *config {
+	r := c.copy()
+	r.commitStrategy = s
+	return r
+}
+
+// WithConcurrency returns a Config with the number of workers a Consumer
+// uses to handle messages concurrently.  By default, messages from different
+// partitions may be handled concurrently; messages from the same partition are
+// handled by the same worker in the order in which they were received (see
+// also: WithOrdering()).
+//
+// Offsets are committed (if auto-commit is disabled) as the highest offset in
+// each partition below which all messages have been handled.
+//
+// A concurrency of 1 or less (the default) handles each message in turn.
+func (c *config) WithConcurrency(n int) *config {
+	r := c.copy()
+	r.concurrency = n
+	return r
+}
+
+// WithDeadLetterFunc returns a Config with a function to be called by a
+// Consumer to send a message to a dead-letter when a FailurePolicy returns
+// DeadLetterMessage.  This replaces any dead-letter topic.
+func (c *config) WithDeadLetterFunc(fn DeadLetterFunc) *config {
+	r := c.copy()
+	r.deadLetter = fn
+	r.deadLetterTopic = ""
+	return r
+}
+
+// WithDeadLetterTopic returns a Config with a topic to which a Consumer sends
+// a message when a FailurePolicy returns DeadLetterMessage.  This replaces any
+// dead-letter func.
+//
+// Messages are sent to the dead-letter topic by a producer created by the
+// Consumer using the same config (and any ProducerHooks).  The dead-letter
+// message has the key, value and headers of the failed message with additional
+// headers identifying the original topic, partition, offset and timestamp of
+// the message, the error, the number of attempts and the consumer group.
+func (c *config) WithDeadLetterTopic(topic string) *config {
+	r := c.copy()
+	r.deadLetter = nil
+	r.deadLetterTopic = topic
+	return r
+}
+
+// WithDeliveryTimeout returns a Config with the maximum time that a producer
+// waits for the delivery event for a message produced by MustProduce (or
+// MustProduceContext), after which an ErrDeliveryTimeout is returned.  The
+// message may yet be delivered; to limit the time for which the producer
+// attempts delivery, set "delivery.timeout.ms".
+//
+// A zero timeout (the default) waits for the delivery event indefinitely
+// (unless the context passed to MustProduceContext is done).
+func (c *config) WithDeliveryTimeout(d time.Duration) *config {
+	r := c.copy()
+	r.deliveryTimeout = d
+	return r
+}
+
+// WithDrainTimeout returns a Config with a deadline for any in-flight message
+// handler to complete when a Consumer is stopped by cancelling the context
+// passed to Run().  When the deadline expires, the context passed to the
+// handler is cancelled.
+//
+// A zero timeout (the default) allows an in-flight handler to run to completion.
+func (c *config) WithDrainTimeout(d time.Duration) *config {
+	r := c.copy()
+	r.drainTimeout = d
+	return r
+}
+
+// WithFailurePolicy returns a Config with a FailurePolicy to be applied when a
+// message handler returns an error.  A FailurePolicy may also be set for a
+// specific topic, using the OnFailure() option of WithMessageHandler().
+//
+// If no FailurePolicy is configured, failed messages are sent to the
+// dead-letter (if configured) or otherwise skipped.
+func (c *config) WithFailurePolicy(policy FailurePolicy) *config {
+	r := c.copy()
+	r.failurePolicy = policy
+	return r
+}
+
 func (c *config) WithHooks(hooks interface{}) *config {
 	_, consumerHooks := hooks.(_hooks.ConsumerHooks)
 	_, producerHooks := hooks.(_hooks.ProducerHooks)
@@ -88,9 +315,51 @@ func (c *config) WithHooks(hooks interface{}) *config {
 	return r
 }
 
+// WithLogger returns a Config with a Logger to be used by a Consumer.  If no
+// Logger is configured, the standard log package logger is used.
+func (c *config) WithLogger(logger Logger) *config {
+	r := c.copy()
+	r.logger = logger
+	return r
+}
+
+// WithMiddleware returns a Config with the specified MessageMiddleware added
+// to any middleware already configured.  Equivalent to Use(middleware).
 func (c *config) WithMiddleware(middleware MessageMiddleware) *config {
+	return c.Use(middleware)
+}
+
+// Use returns a Config with the specified MessageMiddleware added to any
+// middleware already configured.  Middleware is applied to each message in the
+// order added.
+//
+// For a Consumer, middleware is applied to each message before it is passed
+// to the handler.  If a middleware returns an error, the handler is not called
+// and the error is subject to the FailurePolicy for the topic, as if returned
+// by the handler.  If a middleware returns a nil message (and no error), the
+// message is not passed to the handler.  In a Consumer configured for
+// concurrency, middleware may be called concurrently.
+//
+// For a Producer, middleware is applied to each message before it is produced
+// (e.g. to add headers or validate the message).  If a middleware returns an
+// error the message is not produced and the error is returned; if it returns a
+// nil message, the message is not produced.
+func (c *config) Use(middleware ...MessageMiddleware) *config {
 	r := c.copy()
-	r.middleware = middleware
+	r.middleware.messages = append(r.middleware.messages, middleware...)
+	return r
+}
+
+// Wrap returns a Config with the specified HandlerMiddleware added to any
+// already configured.  Each MessageHandler of a Consumer is wrapped by the
+// HandlerMiddleware, the first added being the outermost, such that it is
+// called first and returns last.  HandlerMiddleware wraps any MessageMiddleware
+// as well as the handler itself.
+//
+// HandlerMiddleware is not applied to BatchMessageHandlers.
+func (c *config) Wrap(middleware ...HandlerMiddleware) *config {
+	r := c.copy()
+	r.middleware.handlers = append(r.middleware.handlers, middleware...)
 	return r
 }
 
@@ -122,8 +391,214 @@ func (c *config) WithIdempotence(v bool) *config {
 	return r
 }
 
-func (c *config) WithMessageHandler(t string, fn MessageHandler) *config {
+// WithMessageHandler returns a Config with a MessageHandler for the specified
+// topic.
+//
+// A topic with a "^" prefix is a regular expression; the handler is called for
+// messages on any topic with a matching name, including topics created after
+// the consumer has started.  A handler for a specific topic is used in
+// preference to any pattern; where more than one pattern matches a topic, the
+// most specific pattern (the one with the longest literal prefix) is used.
+//
+// Messages are routed to handlers by matching topics against the pattern using
+// the Go regexp package (RE2 syntax), whereas the topics to be consumed are
+// matched by librdkafka, using its own regular expression engine.  A pattern
+// should therefore use only syntax common to both (e.g. literals, character
+// classes, "." and the "*", "+" and "?" operators).  An invalid pattern is
+// reported by NewConsumer, which returns an ErrInvalidTopicPattern.
+//
+// Options for the topic (e.g. OnFailure, RetryTopics or Timeout) may be
+// specified as HandlerOptions.
+func (c *config) WithMessageHandler(t string, fn MessageHandler, opts ...HandlerOption) *config {
+	r := c.copy()
+
+	h := messageHandler{fn: fn}
+	for _, opt := range opts {
+		opt(&h)
+	}
+	r.messageHandlers[t] = h
+
+	return r
+}
+
+// WithOnPartitionsAssigned returns a Config with a func to be called by a
+// Consumer when partitions are assigned to it (e.g. to warm caches).
+func (c *config) WithOnPartitionsAssigned(fn PartitionsFunc) *config {
+	r := c.copy()
+	r.onAssigned = fn
+	return r
+}
+
+// WithOnPartitionsRevoked returns a Config with a func to be called by a
+// Consumer when partitions are revoked from it (e.g. to flush state).  The
+// func is called once in-flight messages on the revoked partitions have
+// completed and the offsets of completed messages committed.
+func (c *config) WithOnPartitionsRevoked(fn PartitionsFunc) *config {
+	r := c.copy()
+	r.onRevoked = fn
+	return r
+}
+
+// WithPartitionAssignmentStrategy returns a Config with the strategy used to
+// assign partitions to members of a consumer group.  A strategy of
+// "cooperative-sticky" enables incremental (cooperative) rebalancing, in which
+// only the partitions that move between consumers are revoked and assigned.
+func (c *config) WithPartitionAssignmentStrategy(strategy string) *config {
+	r := c.copy()
+	r.config[key[partitionAssignmentStrategy]] = strategy
+	return r
+}
+
+// WithStartFromEarliest returns a Config with which a Consumer consumes the
+// specified topics (or all topics, if none are specified) from the earliest
+// available message.  See WithStartFromOffset.
+func (c *config) WithStartFromEarliest(topics ...string) *config {
+	return c.WithStartFromOffset(kafka.OffsetBeginning, topics...)
+}
+
+// WithStartFromLatest returns a Config with which a Consumer consumes only
+// messages produced to the specified topics (or all topics, if none are
+// specified) after their partitions are assigned.  See WithStartFromOffset.
+func (c *config) WithStartFromLatest(topics ...string) *config {
+	return c.WithStartFromOffset(kafka.OffsetEnd, topics...)
+}
+
+// WithStartFromOffset returns a Config with which a Consumer consumes the
+// partitions of the specified topics (or all topics, if none are specified)
+// from the specified offset, rather than from the offset committed for the
+// consumer group.  A start position for a specific topic takes precedence over
+// one for all topics.
+//
+// The start position is applied only the first time each partition is
+// assigned to the Consumer, before any messages from the partition are
+// handled.  Topics must be identified by name; start positions are not applied
+// to topic patterns.
+func (c *config) WithStartFromOffset(offset kafka.Offset, topics ...string) *config {
+	return c.withStartPosition(startPosition{offset: offset}, topics)
+}
+
+// WithStartFromTime returns a Config with which a Consumer consumes the
+// partitions of the specified topics (or all topics, if none are specified)
+// from the earliest message with a timestamp at or after the specified time
+// (e.g. to reprocess messages after a bug fix).  The offset of the message on
+// each partition is resolved when the partition is assigned.  If there is no
+// such message, only messages produced after the partition is assigned are
+// consumed.  See WithStartFromOffset.
+func (c *config) WithStartFromTime(t time.Time, topics ...string) *config {
+	return c.withStartPosition(startPosition{time: t}, topics)
+}
+
+// WithProducerHooks returns a Config with ProducerHooks to be used by any
+// producer created using the config where the hooks set by WithHooks() are
+// not ProducerHooks.  This allows a single config to provide hooks for both a
+// Consumer and any producer it creates (e.g. for a dead-letter topic).
+func (c *config) WithProducerHooks(hooks _hooks.ProducerHooks) *config {
+	r := c.copy()
+	r.producerHooks = hooks
+	return r
+}
+
+// WithRepanic returns a Config which determines whether a Consumer re-panics
+// when a handler panics.  By default a panic in a handler is recovered and
+// treated as the handler returning an ErrHandlerPanic (subject to the
+// FailurePolicy for the topic).  With repanic, the ErrHandlerPanic is logged
+// (including the stack) and the consumer then panics with it; this is
+// intended for use in development, where a crash is more visible.
+func (c *config) WithRepanic(v bool) *config {
+	r := c.copy()
+	r.repanic = v
+	return r
+}
+
+// WithSchemaRegistry returns a Config with the schema registry Client (from the
+// schemaregistry package of confluent-kafka-go) used by schema registry serdes
+// (e.g. AvroSerializer) created using the config.  Timeouts for requests to
+// the schema registry are configured on the schemaregistry.Config of the
+// client (RequestTimeoutMs and ConnectionTimeoutMs).
+//
+// e.g.
+//
+//	client, err := schemaregistry.NewClient(schemaregistry.NewConfig("http://localhost:8081"))
+//	if err != nil {
+//		return err
+//	}
+//	cfg = cfg.WithSchemaRegistry(client)
+func (c *config) WithSchemaRegistry(client schemaregistry.Client) *config {
+	r := c.copy()
+	r.schemaRegistry = client
+	return r
+}
+
+// WithSubjectNameStrategy returns a Config with the SubjectNameStrategy which
+// determines the subjects under which schema registry serdes created using
+// the config register (or look up) schemas.  The default is
+// TopicNameStrategy().
+func (c *config) WithSubjectNameStrategy(s SubjectNameStrategy) *config {
+	r := c.copy()
+	r.subjectNameStrategy = s
+	return r
+}
+
+// WithTransactionalHandler returns a Config with a TransactionalHandler for the
+// specified topic (or topic pattern; see WithMessageHandler).  The Consumer
+// must be an exactly-once consumer (see WithTransactionalId); otherwise the
+// handler is not called and the message fails with ErrNotTransactional.
+func (c *config) WithTransactionalHandler(t string, fn TransactionalHandler, opts ...HandlerOption) *config {
+	return c.WithMessageHandler(t, transactionalHandler(fn), opts...)
+}
+
+// WithTransactionalId returns a Config with the transactional id of a
+// transactional producer.  Transactions are initialised when the producer is
+// created; messages may then be produced only within a transaction (see
+// producer.Transact).
+//
+// The transactional id identifies the producer across restarts: creating a
+// producer with the same id fences any other producer with that id, whose
+// transactions then fail.
+//
+// A Consumer with a transactional id is an exactly-once consumer.  Each
+// message (or batch) is handled in a transaction of a transactional producer,
+// to which the offsets of the message are sent, so that messages produced by
+// a TransactionalHandler (see WithTransactionalHandler) are delivered if and
+// only if the message is committed as consumed.
+//
+// If a handler fails the transaction is aborted before the FailurePolicy is
+// applied; the offsets of messages that are then skipped (or forwarded to a
+// dead-letter or retry topic, which is not part of the transaction) are
+// committed in a transaction of their own.  If a transaction cannot be
+// committed it is aborted and the failure is subject to the FailurePolicy, as
+// a failed attempt to handle the message.  A message that is retried is
+// consumed again (after any backoff imposed by the policy); if the partition
+// has since been reassigned by a rebalance, by the consumer to which it is now
+// assigned.  If the producer is fenced (or fails with any other fatal error)
+// the consumer stops, returning the error.
+//
+// An exactly-once consumer handles messages one at a time, so the Config may
+// not specify concurrency; auto-commit and any CommitStrategy are ignored.
+func (c *config) WithTransactionalId(id string) *config {
+	r := c.copy()
+	r.config[key[transactionalId]] = id
+	return r
+}
+
+// WithUseLatestSchema returns a Config which determines whether schema registry
+// serializers created using the config encode values using the latest schema
+// registered for the subject, rather than registering their own schema (the
+// default).  Use the latest schema where schemas are registered by some other
+// process (e.g. a deployment pipeline) and producers are not permitted to
+// register schemas.
+func (c *config) WithUseLatestSchema(v bool) *config {
+	r := c.copy()
+	r.useLatestSchema = v
+	return r
+}
+
+// WithOrdering returns a Config with the Ordering of messages handled by a
+// Consumer configured for concurrency.  With KeyOrder, messages on the same
+// partition with different keys may be handled concurrently; the committed
+// offset for each partition never passes the lowest offset still in-flight.
+func (c *config) WithOrdering(o Ordering) *config {
 	r := c.copy()
-	r.messageHandlers[t] = fn
+	r.ordering = o
 	return r
 }
@@ -12,7 +12,9 @@ const (
 	groupId
 	lingerMs
 	maxInFlightRequestsPerConnections
+	partitionAssignmentStrategy
 	retries
+	transactionalId
 )
 
 var key = map[configKeyId]string{
@@ -25,5 +27,7 @@ var key = map[configKeyId]string{
 	groupId:                           "group.id",                              // C
 	lingerMs:                          "linger.ms",                             // P
 	maxInFlightRequestsPerConnections: "max.in.flight.requests.per.connection", // P
+	partitionAssignmentStrategy:       "partition.assignment.strategy",         // C
 	retries:                           "retries",                               // P, C?
+	transactionalId:                   "transactional.id",                      // P
 }
@@ -2,8 +2,10 @@ package kafka
 
 import (
 	"context"
+	"fmt"
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/confluentinc/confluent-kafka-go/kafka"
 	"github.com/deltics/go-kafka/hooks"
@@ -36,8 +38,8 @@ func Test_Config_copy(t *testing.T) {
 				t.Error("wanted the original hooks, got a copy")
 			}
 		}
-		if copy.middleware == nil {
-			t.Error("wanted the original middleware, got nil")
+		if len(copy.middleware.messages) != 1 {
+			t.Error("wanted the original middleware, got none")
 		}
 	})
 
@@ -99,6 +101,26 @@ func Test_Config_With(t *testing.T) {
 	})
 }
 
+func Test_Config_WithAssignment(t *testing.T) {
+	tp := partition{topic: "topic", id: 1}.topicPartition(kafka.OffsetBeginning)
+	cfg := NewConfig()
+	copy := cfg.WithAssignment(tp)
+
+	t.Run("returns a copy of the config", func(t *testing.T) {
+		if copy == cfg {
+			t.Error("got the original, wanted a copy")
+		}
+	})
+
+	t.Run("sets assignment", func(t *testing.T) {
+		wanted := fmt.Sprintf("%v", []kafka.TopicPartition{tp})
+		got := fmt.Sprintf("%v", copy.assignment)
+		if wanted != got {
+			t.Errorf("wanted %v, got %v", wanted, got)
+		}
+	})
+}
+
 func Test_Config_WithAutoCommit(t *testing.T) {
 	wanted := true
 	cfg := NewConfig()
@@ -118,6 +140,62 @@ func Test_Config_WithAutoCommit(t *testing.T) {
 	})
 }
 
+func Test_Config_WithBackpressure(t *testing.T) {
+	wanted := 10
+	cfg := NewConfig()
+	copy := cfg.WithBackpressure(wanted)
+
+	t.Run("returns a copy of the config", func(t *testing.T) {
+		if copy == cfg {
+			t.Error("got the original, wanted a copy")
+		}
+	})
+
+	t.Run("sets backpressure threshold", func(t *testing.T) {
+		got := copy.backpressure
+		if wanted != got {
+			t.Errorf("wanted %v, got %v", wanted, got)
+		}
+	})
+}
+
+func Test_Config_WithBatchMessageHandler(t *testing.T) {
+	topic := "topic"
+	handler := func(context.Context, []*kafka.Message) error { return nil }
+	cfg := NewConfig()
+	copy := cfg.WithBatchMessageHandler(topic, handler, 100, time.Second)
+
+	t.Run("returns a copy of the config", func(t *testing.T) {
+		if copy == cfg {
+			t.Error("got the original, wanted a copy")
+		}
+	})
+
+	t.Run("sets batch message handler for topic", func(t *testing.T) {
+		wanted := reflect.ValueOf(handler).Pointer()
+		got := reflect.ValueOf(copy.messageHandlers[topic].batchFn).Pointer()
+		if wanted != got {
+			t.Errorf("wanted %v, got %v", wanted, got)
+		}
+	})
+
+	t.Run("sets batch size and wait", func(t *testing.T) {
+		h := copy.messageHandlers[topic]
+		if h.batchSize != 100 || h.batchWait != time.Second {
+			t.Errorf("wanted %v/%v, got %v/%v", 100, time.Second, h.batchSize, h.batchWait)
+		}
+	})
+
+	t.Run("panics if size is less than 1", func(t *testing.T) {
+		defer func() {
+			if r := recover(); r == nil {
+				t.Error("did not panic")
+			}
+		}()
+		cfg.WithBatchMessageHandler(topic, handler, 0, time.Second)
+	})
+}
+
 func Test_Config_WithBatchSize(t *testing.T) {
 	wanted := 10
 	cfg := NewConfig()
@@ -180,6 +258,154 @@ func Test_Config_WithBootstrapServers(t *testing.T) {
 	})
 }
 
+func Test_Config_WithDeliveryTimeout(t *testing.T) {
+	wanted := 5 * time.Second
+	cfg := NewConfig()
+	copy := cfg.WithDeliveryTimeout(wanted)
+
+	t.Run("returns a copy of the config", func(t *testing.T) {
+		if copy == cfg {
+			t.Error("got the original, wanted a copy")
+		}
+	})
+
+	t.Run("sets delivery timeout", func(t *testing.T) {
+		got := copy.deliveryTimeout
+		if wanted != got {
+			t.Errorf("wanted %v, got %v", wanted, got)
+		}
+	})
+}
+
+func Test_Config_WithDrainTimeout(t *testing.T) {
+	wanted := 5 * time.Second
+	cfg := NewConfig()
+	copy := cfg.WithDrainTimeout(wanted)
+
+	t.Run("returns a copy of the config", func(t *testing.T) {
+		if copy == cfg {
+			t.Error("got the original, wanted a copy")
+		}
+	})
+
+	t.Run("sets drain timeout", func(t *testing.T) {
+		got := copy.drainTimeout
+		if wanted != got {
+			t.Errorf("wanted %v, got %v", wanted, got)
+		}
+	})
+}
+
+func Test_Config_WithCommitStrategy(t *testing.T) {
+	wanted := CommitEvery(10)
+	cfg := NewConfig()
+	copy := cfg.WithCommitStrategy(wanted)
+
+	t.Run("returns a copy of the config", func(t *testing.T) {
+		if copy == cfg {
+			t.Error("got the original, wanted a copy")
+		}
+	})
+
+	t.Run("sets commit strategy", func(t *testing.T) {
+		got := copy.commitStrategy
+		if wanted != got {
+			t.Errorf("wanted %v, got %v", wanted, got)
+		}
+	})
+}
+
+func Test_Config_WithConcurrency(t *testing.T) {
+	wanted := 4
+	cfg := NewConfig()
+	copy := cfg.WithConcurrency(wanted)
+
+	t.Run("returns a copy of the config", func(t *testing.T) {
+		if copy == cfg {
+			t.Error("got the original, wanted a copy")
+		}
+	})
+
+	t.Run("sets concurrency", func(t *testing.T) {
+		got := copy.concurrency
+		if wanted != got {
+			t.Errorf("wanted %v, got %v", wanted, got)
+		}
+	})
+}
+
+func Test_Config_WithDeadLetterFunc(t *testing.T) {
+	fn := func(context.Context, *Failure) error { return nil }
+	cfg := NewConfig()
+	copy := cfg.WithDeadLetterFunc(fn)
+
+	t.Run("returns a copy of the config", func(t *testing.T) {
+		if copy == cfg {
+			t.Error("got the original, wanted a copy")
+		}
+	})
+
+	t.Run("sets dead-letter func", func(t *testing.T) {
+		wanted := reflect.ValueOf(fn).Pointer()
+		got := reflect.ValueOf(copy.deadLetter).Pointer()
+		if wanted != got {
+			t.Errorf("wanted %v, got %v", wanted, got)
+		}
+	})
+
+	t.Run("clears dead-letter topic", func(t *testing.T) {
+		copy := cfg.WithDeadLetterTopic("topic").WithDeadLetterFunc(fn)
+		if copy.deadLetterTopic != "" {
+			t.Errorf("wanted no dead-letter topic, got %q", copy.deadLetterTopic)
+		}
+	})
+}
+
+func Test_Config_WithDeadLetterTopic(t *testing.T) {
+	wanted := "topic"
+	cfg := NewConfig().WithDeadLetterFunc(func(context.Context, *Failure) error { return nil })
+	copy := cfg.WithDeadLetterTopic(wanted)
+
+	t.Run("returns a copy of the config", func(t *testing.T) {
+		if copy == cfg {
+			t.Error("got the original, wanted a copy")
+		}
+	})
+
+	t.Run("sets dead-letter topic", func(t *testing.T) {
+		got := copy.deadLetterTopic
+		if wanted != got {
+			t.Errorf("wanted %q, got %q", wanted, got)
+		}
+	})
+
+	t.Run("clears dead-letter func", func(t *testing.T) {
+		if copy.deadLetter != nil {
+			t.Error("wanted no dead-letter func")
+		}
+	})
+}
+
+func Test_Config_WithFailurePolicy(t *testing.T) {
+	policy := StopOnFailure()
+	cfg := NewConfig()
+	copy := cfg.WithFailurePolicy(policy)
+
+	t.Run("returns a copy of the config", func(t *testing.T) {
+		if copy == cfg {
+			t.Error("got the original, wanted a copy")
+		}
+	})
+
+	t.Run("sets failure policy", func(t *testing.T) {
+		wanted := reflect.ValueOf(policy).Pointer()
+		got := reflect.ValueOf(copy.failurePolicy).Pointer()
+		if wanted != got {
+			t.Errorf("wanted %v, got %v", wanted, got)
+		}
+	})
+}
+
 func Test_Config_WithHooks(t *testing.T) {
 	wanted := mock.ConsumerHooks()
 	cfg := NewConfig()
@@ -219,13 +445,43 @@ func Test_Config_WithMiddleware(t *testing.T) {
 		}
 	})
 
-	t.Run("sets middleware", func(t *testing.T) {
+	t.Run("adds middleware", func(t *testing.T) {
 		wanted := reflect.ValueOf(middleware).Pointer()
-		got := reflect.ValueOf(copy.middleware).Pointer()
+		got := reflect.ValueOf(copy.middleware.messages[0]).Pointer()
 		if wanted != got {
 			t.Errorf("wanted %v, got %v", wanted, got)
 		}
 	})
+
+	t.Run("adds to existing middleware", func(t *testing.T) {
+		copy := copy.WithMiddleware(middleware)
+
+		wanted := 2
+		got := len(copy.middleware.messages)
+		if wanted != got {
+			t.Errorf("wanted %d middleware, got %d", wanted, got)
+		}
+	})
+}
+
+func Test_Config_Wrap(t *testing.T) {
+	middleware := func(h MessageHandler) MessageHandler { return h }
+	cfg := NewConfig()
+	copy := cfg.Wrap(middleware, middleware)
+
+	t.Run("returns a copy of the config", func(t *testing.T) {
+		if copy == cfg {
+			t.Error("got the original, wanted a copy")
+		}
+	})
+
+	t.Run("adds handler middleware", func(t *testing.T) {
+		wanted := 2
+		got := len(copy.middleware.handlers)
+		if wanted != got {
+			t.Errorf("wanted %d middleware, got %d", wanted, got)
+		}
+	})
 }
 
 func Test_Config_WithNoClient(t *testing.T) {
@@ -299,7 +555,313 @@ func Test_Config_WithMessageHandler(t *testing.T) {
 
 	t.Run("sets message handler for topic", func(t *testing.T) {
 		wanted := reflect.ValueOf(handler).Pointer()
-		got := reflect.ValueOf(copy.messageHandlers[topic]).Pointer()
+		got := reflect.ValueOf(copy.messageHandlers[topic].fn).Pointer()
+		if wanted != got {
+			t.Errorf("wanted %v, got %v", wanted, got)
+		}
+	})
+}
+
+func Test_Config_WithMessageHandlerPattern(t *testing.T) {
+	handler := func(context.Context, *kafka.Message) error { return nil }
+
+	t.Run("sets message handler for pattern", func(t *testing.T) {
+		topic := "^topic-.*"
+		cfg := NewConfig().WithMessageHandler(topic, handler)
+
+		if _, ok := cfg.messageHandlers[topic]; !ok {
+			t.Errorf("no handler for %q", topic)
+		}
+	})
+
+	t.Run("does not panic if the pattern is invalid", func(t *testing.T) {
+		defer func() {
+			if r := recover(); r != nil {
+				t.Errorf("unexpected panic: %v", r)
+			}
+		}()
+		NewConfig().WithMessageHandler("^topic-(", handler)
+	})
+}
+
+func Test_Config_WithMessageHandlerOptions(t *testing.T) {
+	topic := "topic"
+	policy := StopOnFailure()
+	handler := func(context.Context, *kafka.Message) error { return nil }
+	cfg := NewConfig().WithMessageHandler(topic, handler, OnFailure(policy), Timeout(time.Second))
+
+	t.Run("sets failure policy for topic", func(t *testing.T) {
+		wanted := reflect.ValueOf(policy).Pointer()
+		got := reflect.ValueOf(cfg.messageHandlers[topic].failurePolicy).Pointer()
+		if wanted != got {
+			t.Errorf("wanted %v, got %v", wanted, got)
+		}
+	})
+
+	t.Run("sets timeout for topic", func(t *testing.T) {
+		wanted := time.Second
+		got := cfg.messageHandlers[topic].timeout
+		if wanted != got {
+			t.Errorf("wanted %v, got %v", wanted, got)
+		}
+	})
+}
+
+func Test_Config_WithOnPartitionsAssigned(t *testing.T) {
+	fn := func(context.Context, []kafka.TopicPartition) error { return nil }
+	cfg := NewConfig()
+	copy := cfg.WithOnPartitionsAssigned(fn)
+
+	t.Run("returns a copy of the config", func(t *testing.T) {
+		if copy == cfg {
+			t.Error("got the original, wanted a copy")
+		}
+	})
+
+	t.Run("sets on partitions assigned func", func(t *testing.T) {
+		wanted := reflect.ValueOf(fn).Pointer()
+		got := reflect.ValueOf(copy.onAssigned).Pointer()
+		if wanted != got {
+			t.Errorf("wanted %v, got %v", wanted, got)
+		}
+	})
+}
+
+func Test_Config_WithOnPartitionsRevoked(t *testing.T) {
+	fn := func(context.Context, []kafka.TopicPartition) error { return nil }
+	cfg := NewConfig()
+	copy := cfg.WithOnPartitionsRevoked(fn)
+
+	t.Run("returns a copy of the config", func(t *testing.T) {
+		if copy == cfg {
+			t.Error("got the original, wanted a copy")
+		}
+	})
+
+	t.Run("sets on partitions revoked func", func(t *testing.T) {
+		wanted := reflect.ValueOf(fn).Pointer()
+		got := reflect.ValueOf(copy.onRevoked).Pointer()
+		if wanted != got {
+			t.Errorf("wanted %v, got %v", wanted, got)
+		}
+	})
+}
+
+func Test_Config_WithPartitionAssignmentStrategy(t *testing.T) {
+	wanted := "cooperative-sticky"
+	cfg := NewConfig()
+	copy := cfg.WithPartitionAssignmentStrategy(wanted)
+
+	t.Run("returns a copy of the config", func(t *testing.T) {
+		if copy == cfg {
+			t.Error("got the original, wanted a copy")
+		}
+	})
+
+	t.Run("sets partition.assignment.strategy", func(t *testing.T) {
+		got := copy.config[key[partitionAssignmentStrategy]]
+		if wanted != got {
+			t.Errorf("wanted %v, got %v", wanted, got)
+		}
+	})
+}
+
+func Test_Config_WithStartFromOffset(t *testing.T) {
+	cfg := NewConfig()
+	copy := cfg.WithStartFromOffset(42, "topicA", "topicB")
+
+	t.Run("returns a copy of the config", func(t *testing.T) {
+		if copy == cfg {
+			t.Error("got the original, wanted a copy")
+		}
+	})
+
+	t.Run("sets start position for topics", func(t *testing.T) {
+		for _, topic := range []string{"topicA", "topicB"} {
+			pos, _ := copy.startPositionFor(topic)
+			wanted := kafka.Offset(42)
+			got := pos.offset
+			if wanted != got {
+				t.Errorf("%s: wanted %v, got %v", topic, wanted, got)
+			}
+		}
+	})
+
+	t.Run("does not set start position for other topics", func(t *testing.T) {
+		if _, ok := copy.startPositionFor("topicC"); ok {
+			t.Error("got start position for topicC")
+		}
+	})
+}
+
+func Test_Config_WithStartFromTime(t *testing.T) {
+	wanted := time.Now()
+	cfg := NewConfig()
+	copy := cfg.WithStartFromTime(wanted)
+
+	t.Run("returns a copy of the config", func(t *testing.T) {
+		if copy == cfg {
+			t.Error("got the original, wanted a copy")
+		}
+	})
+
+	t.Run("sets start position for all topics", func(t *testing.T) {
+		pos, _ := copy.startPositionFor("topic")
+		got := pos.time
+		if !wanted.Equal(got) {
+			t.Errorf("wanted %v, got %v", wanted, got)
+		}
+	})
+
+	t.Run("does not modify the original", func(t *testing.T) {
+		if _, ok := cfg.startPositionFor("topic"); ok {
+			t.Error("original config has start position")
+		}
+	})
+}
+
+func Test_Config_WithProducerHooks(t *testing.T) {
+	wanted := mock.ProducerHooks()
+	cfg := NewConfig().WithHooks(mock.ConsumerHooks())
+	copy := cfg.WithProducerHooks(wanted)
+
+	t.Run("returns a copy of the config", func(t *testing.T) {
+		if copy == cfg {
+			t.Error("got the original, wanted a copy")
+		}
+	})
+
+	t.Run("sets producer hooks", func(t *testing.T) {
+		got := copy.producerHooks
+		if wanted != got {
+			t.Errorf("wanted %v, got %v", wanted, got)
+		}
+	})
+}
+
+func Test_Config_WithOrdering(t *testing.T) {
+	wanted := KeyOrder
+	cfg := NewConfig()
+	copy := cfg.WithOrdering(wanted)
+
+	t.Run("returns a copy of the config", func(t *testing.T) {
+		if copy == cfg {
+			t.Error("got the original, wanted a copy")
+		}
+	})
+
+	t.Run("sets ordering", func(t *testing.T) {
+		got := copy.ordering
+		if wanted != got {
+			t.Errorf("wanted %v, got %v", wanted, got)
+		}
+	})
+}
+
+func Test_Config_WithRepanic(t *testing.T) {
+	cfg := NewConfig()
+	copy := cfg.WithRepanic(true)
+
+	t.Run("returns a copy of the config", func(t *testing.T) {
+		if copy == cfg {
+			t.Error("got the original, wanted a copy")
+		}
+	})
+
+	t.Run("sets repanic", func(t *testing.T) {
+		if !copy.repanic {
+			t.Error("wanted repanic, got false")
+		}
+	})
+}
+
+func Test_Config_WithSchemaRegistry(t *testing.T) {
+	sr := mock.SchemaRegistry()
+	cfg := NewConfig()
+	copy := cfg.WithSchemaRegistry(sr)
+
+	t.Run("returns a copy of the config", func(t *testing.T) {
+		if copy == cfg {
+			t.Error("got the original, wanted a copy")
+		}
+	})
+
+	t.Run("sets schema registry", func(t *testing.T) {
+		if copy.schemaRegistry != sr {
+			t.Errorf("wanted %v, got %v", sr, copy.schemaRegistry)
+		}
+	})
+}
+
+func Test_Config_WithSubjectNameStrategy(t *testing.T) {
+	s := RecordNameStrategy()
+	cfg := NewConfig()
+	copy := cfg.WithSubjectNameStrategy(s)
+
+	t.Run("returns a copy of the config", func(t *testing.T) {
+		if copy == cfg {
+			t.Error("got the original, wanted a copy")
+		}
+	})
+
+	t.Run("sets subject name strategy", func(t *testing.T) {
+		wanted := reflect.ValueOf(s).Pointer()
+		got := reflect.ValueOf(copy.subjectNameStrategy).Pointer()
+		if wanted != got {
+			t.Errorf("wanted %v, got %v", wanted, got)
+		}
+	})
+}
+
+func Test_Config_WithUseLatestSchema(t *testing.T) {
+	cfg := NewConfig()
+	copy := cfg.WithUseLatestSchema(true)
+
+	t.Run("returns a copy of the config", func(t *testing.T) {
+		if copy == cfg {
+			t.Error("got the original, wanted a copy")
+		}
+	})
+
+	t.Run("sets use latest schema", func(t *testing.T) {
+		if !copy.useLatestSchema {
+			t.Error("wanted use latest schema, got false")
+		}
+	})
+}
+
+func Test_Config_WithTransactionalHandler(t *testing.T) {
+	topic := "topic"
+	handler := func(context.Context, *kafka.Message, *Transaction) error { return nil }
+	cfg := NewConfig()
+	copy := cfg.WithTransactionalHandler(topic, handler)
+
+	t.Run("returns a copy of the config", func(t *testing.T) {
+		if copy == cfg {
+			t.Error("got the original, wanted a copy")
+		}
+	})
+
+	t.Run("sets message handler for topic", func(t *testing.T) {
+		if _, ok := copy.messageHandlers[topic]; !ok {
+			t.Errorf("no handler for %q", topic)
+		}
+	})
+}
+
+func Test_Config_WithTransactionalId(t *testing.T) {
+	cfg := NewConfig()
+	copy := cfg.WithTransactionalId("txn")
+
+	t.Run("returns a copy of the config", func(t *testing.T) {
+		if copy == cfg {
+			t.Error("got the original, wanted a copy")
+		}
+	})
+
+	t.Run("sets transactional id", func(t *testing.T) {
+		wanted := "txn"
+		got := copy.config[key[transactionalId]]
 		if wanted != got {
 			t.Errorf("wanted %v, got %v", wanted, got)
 		}
@@ -2,19 +2,57 @@ package kafka
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"time"
 
 	"github.com/confluentinc/confluent-kafka-go/kafka"
 
 	"github.com/deltics/go-kafka/hooks"
 )
 
+// pollTimeout is the maximum time that Run() will wait for a message before
+// checking whether the context has been cancelled.
+const pollTimeout = 100 * time.Millisecond
+
 type Consumer struct {
 	hooks      hooks.ConsumerHooks
 	config     *config
 	consumer   *kafka.Consumer
 	handlers   messageHandlerMap
-	middleware MessageMiddleware
+	routes     map[string]route
+	patterns   []topicPattern // handlers for topic patterns, most specific first
+	logger     Logger
+	deadLetter DeadLetterFunc
+	producer   *producer               // producer for dead-letter and retry topics (if required)
+	paused     map[partition]time.Time // retry topic partitions paused until the time of the next retry
+	pauses     *topicPauses            // topics paused by Pause()
+	throttled  map[partition]bool      // partitions paused by backpressure
+	held       map[partition]bool      // partitions paused on the kafka consumer
+	rewound    map[partition]bool      // paused partitions whose position has been reset since being paused
+	partitions map[partition]bool      // partitions known to be assigned to the consumer
+	autoCommit bool
+	offsets    *offsetTracker
+	workers    *workerPool                 // workers for concurrent handling (if configured)
+	shard      func(*kafka.Message) uint32 // determines the worker for each message
+	batches    map[string]*messageBatch    // batches being accumulated for batch handlers, by topic
+
+	transactions *producer                // transactional producer in which messages are handled (if exactly-once)
+	redeliveries map[partition]redelivery // messages consumed again after a failed transaction, by partition
+
+	commitStrategy CommitStrategy
+	now            func() time.Time // returns the current time (replaced by tests)
+	handled        int              // messages handled since the last commit
+	lastCommit     time.Time        // time of the last commit
+	asyncCommits   asyncCommits     // async commits not yet completed
+
+	runCtx   context.Context // the context passed to Run, with which failure policies are called
+	drainCtx context.Context // the drain context, with which handlers are called and transactions committed
+	requests chan request    // requests to be executed by the goroutine running the consumer
+	done     chan struct{}   // closed when Run returns
+	stopErr  error           // error stopping the consumer raised outside of the consume loop (e.g. by a rebalance)
+
+	started map[partition]bool // partitions to which any start position has been applied
 }
 
 func NewConsumer(cfg *config) (*Consumer, error) {
@@ -29,38 +67,222 @@ func NewConsumer(cfg *config) (*Consumer, error) {
 		}
 	}
 
-	// Create the consumer
+	// A consumer with a transactional id is an exactly-once consumer; messages
+	// are handled (and their offsets committed) in transactions, one at a time
+	transactional := cfg.transactionalId() != ""
+	if transactional && cfg.concurrency > 1 {
+		panic("invalid config: an exactly-once consumer (with a transactional id) cannot be concurrent")
+	}
+
+	// Middleware is applied by wrapping the handlers, so that an error from any
+	// middleware is subject to the FailurePolicy for the topic
+	handlers := cfg.messageHandlers.withMiddleware(cfg.middleware)
+	routes := handlers.routes()
+	patterns, err := handlers.patterns()
+	if err != nil {
+		return nil, err
+	}
+	if err := checkAssignment(cfg.assignment, routes, patterns); err != nil {
+		return nil, err
+	}
+
+	// Create the consumer.  The transactional id is a producer property; the
+	// offsets of an exactly-once consumer are committed only by transactions
+	ccfg := cfg
+	if transactional {
+		ccfg = cfg.WithAutoCommit(false)
+		delete(ccfg.config, key[transactionalId])
+	}
 	var kc *kafka.Consumer
-	var err error
-	if kc, err = hk.Create(cfg.config.configMap()); err != nil {
+	if kc, err = hk.Create(ccfg.config.configMap()); err != nil {
 		return nil, err
 	}
 
-	return &Consumer{
+	logger := cfg.logger
+	if logger == nil {
+		logger = defaultLogger()
+	}
+
+	c := &Consumer{
 		hooks:      hk,
 		config:     cfg.copy(),
 		consumer:   kc,
-		middleware: cfg.middleware,
-		handlers:   cfg.messageHandlers.copy(),
-	}, nil
+		handlers:   handlers,
+		routes:     routes,
+		patterns:   patterns,
+		logger:     logger,
+		deadLetter: cfg.deadLetter,
+		paused:     map[partition]time.Time{},
+		pauses:     newTopicPauses(),
+		throttled:  map[partition]bool{},
+		held:       map[partition]bool{},
+		rewound:    map[partition]bool{},
+		partitions: map[partition]bool{},
+		autoCommit: ccfg.autoCommit(),
+		offsets:    newOffsetTracker(),
+		batches:    map[string]*messageBatch{},
+		now:        time.Now,
+		requests:   make(chan request),
+		done:       make(chan struct{}),
+		started:    map[partition]bool{},
+	}
+
+	c.commitStrategy = cfg.commitStrategy
+	if c.commitStrategy == (CommitStrategy{}) || transactional {
+		c.commitStrategy = CommitEachMessage()
+	}
+
+	if transactional {
+		if c.transactions, err = c.newTransactionalProducer(); err != nil {
+			hk.Close(kc)
+			return nil, err
+		}
+		c.redeliveries = map[partition]redelivery{}
+	}
+
+	// Create a producer for the dead-letter and retry topics (if required)
+	if cfg.deadLetterTopic != "" || c.handlers.hasRetryTiers() {
+		if c.producer, err = c.newProducer(); err != nil {
+			c.Close()
+			return nil, err
+		}
+	}
+	if cfg.deadLetterTopic != "" {
+		c.deadLetter = deadLetterTopicFunc(c.producer, cfg.deadLetterTopic, cfg.groupId())
+	}
+
+	return c, nil
+}
+
+// newProducer creates a producer using the consumer config.  The producer is
+// hooked using any ProducerHooks on the config.  Consumer middleware is not
+// applied to messages produced by the producer, which is not transactional.
+func (c *Consumer) newProducer() (*producer, error) {
+	cfg := c.config.copy()
+	cfg.hooks = nil
+	cfg.middleware = middlewareChain{}
+	delete(cfg.config, key[transactionalId])
+	return NewProducer(cfg)
 }
 
 func (c *Consumer) Close() {
+	if c.producer != nil {
+		c.producer.Close()
+	}
+	if c.transactions != nil {
+		c.transactions.Close()
+	}
 	c.hooks.Close(c.consumer)
 }
 
+// Run subscribes to the topics for which message handlers are configured (or,
+// if the config has an assignment, assigns the partitions of the assignment)
+// and dispatches messages to those handlers until an error occurs or the
+// specified context is cancelled.
+//
+// When the context is cancelled, any in-flight handler is allowed to finish
+// (subject to any drain timeout on the config) and, if successful, the offset
+// of the message is committed before Run returns.  Run returns nil if the
+// consumer was stopped by cancelling the context.
 func (c *Consumer) Run(ctx context.Context) error {
 	defer c.Close()
+	defer close(c.done)
 
-	autoCommit := c.config.autoCommit()
+	// Failure policies are called with the context passed to Run, so that a
+	// policy waiting to retry a message is interrupted when ctx is cancelled
+	c.runCtx = ctx
 
-	if err := c.hooks.Subscribe(c.consumer, c.config.messageHandlers.topicIds(), nil); err != nil {
+	// Handlers are called with a context that is not cancelled when ctx is
+	// cancelled, allowing an in-flight handler to complete before we stop
+	hctx, cancel := drainContext(ctx, c.config.drainTimeout)
+	defer cancel()
+	c.drainCtx = hctx
+
+	if len(c.config.assignment) > 0 {
+		if err := c.assign(hctx); err != nil {
+			return err
+		}
+	} else if err := c.hooks.Subscribe(c.consumer, c.config.messageHandlers.topicIds(), c.rebalanceCb(hctx)); err != nil {
 		return err
 	}
 
+	if c.config.concurrency > 1 {
+		c.workers = newWorkerPool(c.config.concurrency, func(j job) error {
+			return c.handle(hctx, j.route, j.msgs...)
+		})
+		c.shard = partitionShard
+		if c.config.ordering == KeyOrder {
+			c.shard = keyShard
+		}
+	}
+
+	c.lastCommit = c.now()
+
+	err := c.consume(ctx, hctx)
+
+	// Wait for any in-flight handlers to complete before committing the
+	// offsets of all completed messages
+	if c.workers != nil {
+		if werr := c.workers.stop(c.completed); err == nil {
+			err = werr
+		}
+	}
+	if cerr := c.commitAll(); err == nil {
+		err = cerr
+	}
+	if len(c.config.assignment) > 0 {
+		if uerr := c.hooks.Unassign(c.consumer); err == nil {
+			err = uerr
+		}
+	}
+
+	return err
+}
+
+// consume reads messages and dispatches them to handlers until an error
+// occurs or the specified context is cancelled.
+func (c *Consumer) consume(ctx context.Context, hctx context.Context) error {
 	for {
-		msg, err := c.hooks.ReadMessage(c.consumer, -1)
+		if c.workers != nil {
+			if err := c.workers.collect(c.completed); err != nil {
+				return err
+			}
+		}
+
+		// Offsets are committed on every iteration (not only when a message
+		// is handled) so that a commit interval elapses while idle
+		if err := c.commit(); err != nil {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		default:
+		}
+
+		now := c.now()
+		if err := c.resumeRetries(now); err != nil {
+			return err
+		}
+		if err := c.flushExpired(hctx, now); err != nil {
+			return err
+		}
+		if err := c.reconcilePauses(); err != nil {
+			return err
+		}
+
+		c.serveRequests()
+
+		msg, err := c.hooks.ReadMessage(c.consumer, c.readTimeout(now))
+		if c.stopErr != nil {
+			return c.stopErr
+		}
+
 		if err != nil {
+			if isTimeout(err) {
+				continue
+			}
 			// TODO: Check msg for topic/partition info to include in error log
 			return err
 		}
@@ -69,29 +291,222 @@ func (c *Consumer) Run(ctx context.Context) error {
 			continue
 		}
 
+		// A message fetched from a partition before it was paused is read
+		// again when the partition is resumed
+		p := partitionOf(msg.TopicPartition)
+		c.partitions[p] = true
+		if c.held[p] || c.holding(p) {
+			if err = c.hold(p); err != nil {
+				return err
+			}
+			if err = c.rewind(msg); err != nil {
+				return err
+			}
+			continue
+		}
+
 		// Ensure we have a handler (since we subscribe to topics with handlers, this
 		// shouldn't be necessary so if it does happen, it's a panic!)
-		handler, ok := c.handlers[*msg.TopicPartition.Topic]
+		route, ok := c.route(*msg.TopicPartition.Topic)
 		if !ok {
 			panic(fmt.Sprintf("no handler for topic %v", *msg.TopicPartition.Topic))
 		}
 
-		// TODO: If middleware returns an error shouldn't we stop consuming? (or at least
-		//       give the app/service the option, via a callback notification)
-		if c.middleware != nil {
-			msg, err = c.middleware(msg)
-			if err != nil {
-				// TODO: callback notifications
+		// Messages on a retry topic that are not yet due are deferred
+		if route.tier > 0 {
+			if due := retryNotBefore(msg); c.now().Before(due) {
+				if err = c.deferRetry(msg, due); err != nil {
+					return err
+				}
 				continue
 			}
 		}
 
-		err = handler(ctx, msg)
-		if err == nil && !autoCommit {
-			_, err = c.hooks.CommitOffset(c.consumer, []kafka.TopicPartition{msg.TopicPartition})
-			if err != nil {
+		if route.handler.batchFn != nil {
+			if err = c.batch(hctx, route, msg); err != nil {
 				return err
 			}
+			continue
+		}
+
+		c.offsets.dispatched(msg.TopicPartition)
+		c.throttle(msg.TopicPartition)
+
+		if c.workers != nil {
+			if err = c.workers.dispatch(job{route: route, msgs: []*kafka.Message{msg}}, c.shard(msg), c.completed); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if err = c.completed(result{msgs: []*kafka.Message{msg}, err: c.handle(hctx, route, msg)}); err != nil {
+			return err
 		}
 	}
 }
+
+// completed records the result of handling a message (or batch).  If the
+// handler failed (and the consumer should stop) the error is returned.
+func (c *Consumer) completed(r result) error {
+	if r.err != nil {
+		return r.err
+	}
+	for _, msg := range r.msgs {
+		c.offsets.completed(msg.TopicPartition)
+		c.unthrottle(msg.TopicPartition)
+	}
+	c.handled += len(r.msgs)
+	return nil
+}
+
+// request is a func to be executed by the goroutine running the consumer,
+// with a channel on which the error returned by the func is sent.
+type request struct {
+	fn     func() error
+	result chan error
+}
+
+// do executes a func on the goroutine running the consumer, returning the
+// error returned by the func.  If the consumer is not yet running, do waits
+// until it is (or the context is cancelled).
+func (c *Consumer) do(ctx context.Context, fn func() error) error {
+	r := request{fn: fn, result: make(chan error, 1)}
+
+	select {
+	case c.requests <- r:
+		return <-r.result
+	case <-c.done:
+		return ErrConsumerNotRunning
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// serveRequests executes any pending requests.
+func (c *Consumer) serveRequests() {
+	for {
+		select {
+		case r := <-c.requests:
+			r.result <- r.fn()
+		default:
+			return
+		}
+	}
+}
+
+// handle calls the handler for a message (or batch of messages), applying the
+// FailurePolicy for the topic if the handler fails.  An error is returned only
+// if the consumer should stop; otherwise the messages are considered done with
+// (whether handled successfully, skipped, forwarded to a retry topic or sent to
+// the dead-letter).
+func (c *Consumer) handle(ctx context.Context, r route, msgs ...*kafka.Message) error {
+	handler := r.handler
+
+	policy := handler.failurePolicy
+	if policy == nil {
+		policy = c.config.failurePolicy
+	}
+	if policy == nil {
+		switch {
+		case len(handler.retryTiers) > 0:
+			policy = RetryFailedMessagesLater()
+		case c.deadLetter != nil:
+			policy = DeadLetterFailedMessages()
+		default:
+			policy = SkipFailedMessages()
+		}
+	}
+
+	// Attempts made before the message was forwarded to a retry topic (or
+	// consumed again after a failed transaction)
+	prior := c.priorAttempts(msgs[0])
+
+	for attempt := 1; ; attempt++ {
+		err := c.call(ctx, handler, msgs)
+		if err == nil {
+			return nil
+		}
+
+		// A transaction that could not be committed is a failed attempt,
+		// unless the error is fatal
+		tf, txnFailed := err.(errTransactionFailed)
+		if txnFailed && IsFatalError(tf.err) {
+			return tf.err
+		}
+
+		if p, ok := err.(ErrHandlerPanic); ok && c.config.repanic {
+			c.logger.Printf("%v\n%s", p, p.Stack)
+			panic(p)
+		}
+
+		f := &Failure{Message: msgs[0], Err: err, Attempts: prior + attempt}
+		if handler.batchFn != nil {
+			f.Batch = msgs
+		}
+
+		// Messages that cannot be decoded for a typed handler are subject to
+		// the deserialization policy for the handler
+		fp := policy
+		if errors.As(err, &ErrDeserializationFailed{}) {
+			fp = c.deserializationPolicy(handler)
+		}
+
+		switch fp(c.runCtx, f) {
+		case RetryMessage:
+			// The messages of a failed transaction are consumed again
+			if txnFailed {
+				return c.reconsume(f, msgs)
+			}
+			continue
+
+		case StopConsumer:
+			return ErrHandlerFailed{Failure: f}
+
+		case RetryMessageLater:
+			for _, f := range f.messages() {
+				var err error
+				if r.tier < len(handler.retryTiers) {
+					err = c.retryLater(ctx, handler.retryTiers[r.tier], f)
+				} else {
+					err = c.sendToDeadLetter(ctx, f)
+				}
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+
+		case DeadLetterMessage:
+			for _, f := range f.messages() {
+				if err := c.sendToDeadLetter(ctx, f); err != nil {
+					return err
+				}
+			}
+			return nil
+
+		default:
+			for _, msg := range msgs {
+				c.logger.Printf("skipping message %s after %d attempt(s): %v", msg.TopicPartition, f.Attempts, err)
+			}
+			return nil
+		}
+	}
+}
+
+// sendToDeadLetter sends a failed message to the dead-letter.
+func (c *Consumer) sendToDeadLetter(ctx context.Context, f *Failure) error {
+	if c.deadLetter == nil {
+		return ErrDeadLetterFailed{Failure: f, Err: errNoDeadLetter}
+	}
+	if err := c.deadLetter(ctx, f); err != nil {
+		return ErrDeadLetterFailed{Failure: f, Err: err}
+	}
+	return nil
+}
+
+// isTimeout returns true if the specified error is a kafka.Error indicating
+// that an operation timed out.
+func isTimeout(err error) bool {
+	kerr, ok := err.(kafka.Error)
+	return ok && kerr.Code() == kafka.ErrTimedOut
+}
@@ -2,7 +2,11 @@ package kafka
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/confluentinc/confluent-kafka-go/kafka"
 
@@ -128,3 +132,858 @@ func TestThatConsumerMiddlewareIsCalled(t *testing.T) {
 		t.Error("middleware was not called")
 	}
 }
+
+func TestThatTheConsumerIgnoresReadTimeouts(t *testing.T) {
+	// MOCK
+	received := ""
+
+	p := mock.ConsumerHooks()
+	p.Messages([]interface{}{
+		kafka.NewError(kafka.ErrTimedOut, "timed out", false),
+		StringMessage("topicA", "message"),
+	})
+
+	// ARRANGE
+	cfg := NewConfig().WithHooks(p).
+		WithMessageHandler("topicA", func(ctx context.Context, msg *kafka.Message) error {
+			received = string(msg.Value)
+			return nil
+		})
+
+	c, _ := NewConsumer(cfg)
+
+	// ACT
+	c.Run(context.Background())
+
+	// ASSERT
+	if received != "message" {
+		t.Errorf("wanted %q, got %q", "message", received)
+	}
+}
+
+func TestThatRunStopsAndCommitsTheInFlightMessageWhenTheContextIsCancelled(t *testing.T) {
+	// MOCK
+	handled := []string{}
+	committed := []kafka.TopicPartition{}
+
+	p := mock.ConsumerHooks()
+	p.Funcs().CommitOffset = func(c *kafka.Consumer, tpa []kafka.TopicPartition) ([]kafka.TopicPartition, error) {
+		committed = append(committed, tpa...)
+		return tpa, nil
+	}
+	p.Messages([]interface{}{
+		StringMessage("topicA", "first"),
+		StringMessage("topicA", "second"),
+	})
+
+	// ARRANGE
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var handlerErr error
+	cfg := NewConfig().WithHooks(p).
+		WithAutoCommit(false).
+		WithMessageHandler("topicA", func(hctx context.Context, msg *kafka.Message) error {
+			handled = append(handled, string(msg.Value))
+			cancel()
+			handlerErr = hctx.Err()
+			return nil
+		})
+
+	c, _ := NewConsumer(cfg)
+
+	// ACT
+	err := c.Run(ctx)
+
+	// ASSERT
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if handlerErr != nil {
+		t.Errorf("handler context was cancelled: %v", handlerErr)
+	}
+	if len(handled) != 1 || handled[0] != "first" {
+		t.Errorf("wanted only %q to be handled, got %v", "first", handled)
+	}
+	if len(committed) != 1 {
+		t.Errorf("wanted %d offset(s) committed, got %d", 1, len(committed))
+	}
+}
+
+func TestThatTheHandlerContextIsCancelledWhenTheDrainTimeoutExpires(t *testing.T) {
+	// MOCK
+	p := mock.ConsumerHooks()
+	p.Messages([]interface{}{
+		StringMessage("topicA", "message"),
+	})
+
+	// ARRANGE
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	drained := false
+	cfg := NewConfig().WithHooks(p).
+		WithDrainTimeout(10*time.Millisecond).
+		WithMessageHandler("topicA", func(hctx context.Context, msg *kafka.Message) error {
+			cancel()
+			select {
+			case <-hctx.Done():
+				drained = true
+			case <-time.After(time.Second):
+			}
+			return nil
+		})
+
+	c, _ := NewConsumer(cfg)
+
+	// ACT
+	err := c.Run(ctx)
+
+	// ASSERT
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if !drained {
+		t.Error("handler context was not cancelled by the drain timeout")
+	}
+}
+
+type testLogger struct {
+	entries []string
+}
+
+func (l *testLogger) Printf(format string, v ...interface{}) {
+	l.entries = append(l.entries, fmt.Sprintf(format, v...))
+}
+
+func TestThatAFailedMessageIsSkippedAndLoggedByDefault(t *testing.T) {
+	// MOCK
+	committed := 0
+
+	p := mock.ConsumerHooks()
+	p.Funcs().CommitOffset = func(c *kafka.Consumer, tpa []kafka.TopicPartition) ([]kafka.TopicPartition, error) {
+		committed++
+		return tpa, nil
+	}
+	p.Messages([]interface{}{
+		StringMessage("topicA", "message"),
+	})
+
+	// ARRANGE
+	logger := &testLogger{}
+	cfg := NewConfig().WithHooks(p).
+		WithAutoCommit(false).
+		WithLogger(logger).
+		WithMessageHandler("topicA", func(ctx context.Context, msg *kafka.Message) error {
+			return errors.New("failed")
+		})
+
+	c, _ := NewConsumer(cfg)
+
+	// ACT
+	c.Run(context.Background())
+
+	// ASSERT
+	if len(logger.entries) != 1 {
+		t.Errorf("wanted %d log entries, got %d", 1, len(logger.entries))
+	}
+	if committed != 1 {
+		t.Errorf("wanted skipped message to be committed, got %d commits", committed)
+	}
+}
+
+func TestThatAFailedMessageStopsTheConsumerWithStopOnFailure(t *testing.T) {
+	// MOCK
+	committed := 0
+	handlerErr := errors.New("failed")
+
+	p := mock.ConsumerHooks()
+	p.Funcs().CommitOffset = func(c *kafka.Consumer, tpa []kafka.TopicPartition) ([]kafka.TopicPartition, error) {
+		committed++
+		return tpa, nil
+	}
+	p.Messages([]interface{}{
+		StringMessage("topicA", "message"),
+	})
+
+	// ARRANGE
+	cfg := NewConfig().WithHooks(p).
+		WithAutoCommit(false).
+		WithFailurePolicy(StopOnFailure()).
+		WithMessageHandler("topicA", func(ctx context.Context, msg *kafka.Message) error {
+			return handlerErr
+		})
+
+	c, _ := NewConsumer(cfg)
+
+	// ACT
+	err := c.Run(context.Background())
+
+	// ASSERT
+	if _, ok := err.(ErrHandlerFailed); !ok {
+		t.Errorf("wanted %T, got %T", ErrHandlerFailed{}, err)
+	}
+	if !errors.Is(err, handlerErr) {
+		t.Errorf("wanted error wrapping %v, got %v", handlerErr, err)
+	}
+	if committed != 0 {
+		t.Errorf("wanted no commits, got %d", committed)
+	}
+}
+
+func TestThatAPanicInAHandlerIsRecoveredAsAFailure(t *testing.T) {
+	// MOCK
+	p := mock.ConsumerHooks()
+	p.Messages([]interface{}{
+		StringMessage("topicA", "message"),
+	})
+
+	// ARRANGE
+	cfg := NewConfig().WithHooks(p).
+		WithAutoCommit(false).
+		WithFailurePolicy(StopOnFailure()).
+		WithMessageHandler("topicA", func(ctx context.Context, msg *kafka.Message) error {
+			panic("boom")
+		})
+
+	c, _ := NewConsumer(cfg)
+
+	// ACT
+	err := c.Run(context.Background())
+
+	// ASSERT
+	var panicErr ErrHandlerPanic
+	if !errors.As(err, &panicErr) {
+		t.Fatalf("wanted error wrapping %T, got %v", ErrHandlerPanic{}, err)
+	}
+	if panicErr.Value != "boom" {
+		t.Errorf("wanted panic value %q, got %v", "boom", panicErr.Value)
+	}
+	if *panicErr.TopicPartition.Topic != "topicA" {
+		t.Errorf("wanted message from %q, got %v", "topicA", panicErr.TopicPartition)
+	}
+	if len(panicErr.Stack) == 0 {
+		t.Error("wanted stack, got none")
+	}
+}
+
+func TestThatAPanicInAHandlerIsLoggedAndRepanickedWithRepanic(t *testing.T) {
+	// MOCK
+	p := mock.ConsumerHooks()
+	p.Messages([]interface{}{
+		StringMessage("topicA", "message"),
+	})
+
+	// ARRANGE
+	logger := &testLogger{}
+	cfg := NewConfig().WithHooks(p).
+		WithAutoCommit(false).
+		WithLogger(logger).
+		WithRepanic(true).
+		WithMessageHandler("topicA", func(ctx context.Context, msg *kafka.Message) error {
+			panic("boom")
+		})
+
+	c, _ := NewConsumer(cfg)
+
+	// ACT
+	var recovered interface{}
+	func() {
+		defer func() { recovered = recover() }()
+		c.Run(context.Background())
+	}()
+
+	// ASSERT
+	if _, ok := recovered.(ErrHandlerPanic); !ok {
+		t.Errorf("wanted panic with %T, got %v", ErrHandlerPanic{}, recovered)
+	}
+	if len(logger.entries) != 1 {
+		t.Errorf("wanted %d log entries, got %d", 1, len(logger.entries))
+	}
+}
+
+func TestThatAHungHandlerIsAbandonedWhenTheTopicTimeoutExpires(t *testing.T) {
+	// MOCK
+	p := mock.ConsumerHooks()
+	p.Messages([]interface{}{
+		StringMessage("topicA", "message"),
+	})
+
+	// ARRANGE
+	hung := make(chan struct{})
+	defer close(hung)
+
+	cfg := NewConfig().WithHooks(p).
+		WithAutoCommit(false).
+		WithMessageHandler("topicA", func(ctx context.Context, msg *kafka.Message) error {
+			<-hung
+			return nil
+		}, OnFailure(StopOnFailure()), Timeout(10*time.Millisecond))
+
+	c, _ := NewConsumer(cfg)
+
+	// ACT
+	err := c.Run(context.Background())
+
+	// ASSERT
+	var timeoutErr ErrHandlerTimeout
+	if !errors.As(err, &timeoutErr) {
+		t.Fatalf("wanted error wrapping %T, got %v", ErrHandlerTimeout{}, err)
+	}
+	if *timeoutErr.TopicPartition.Topic != "topicA" {
+		t.Errorf("wanted message from %q, got %v", "topicA", timeoutErr.TopicPartition)
+	}
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("wanted error wrapping %v, got %v", context.DeadlineExceeded, err)
+	}
+}
+
+func TestThatAHungHandlerWithATimeoutIsAbandonedWhenTheDrainTimeoutExpires(t *testing.T) {
+	// MOCK
+	p := mock.ConsumerHooks()
+	p.Messages([]interface{}{
+		StringMessage("topicA", "message"),
+	})
+
+	// ARRANGE
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hung := make(chan struct{})
+	defer close(hung)
+
+	cfg := NewConfig().WithHooks(p).
+		WithLogger(&testLogger{}).
+		WithDrainTimeout(10*time.Millisecond).
+		WithMessageHandler("topicA", func(context.Context, *kafka.Message) error {
+			cancel()
+			<-hung
+			return nil
+		}, Timeout(time.Hour))
+
+	c, _ := NewConsumer(cfg)
+
+	// ACT
+	result := make(chan error, 1)
+	go func() { result <- c.Run(ctx) }()
+
+	// ASSERT
+	select {
+	case <-result:
+	case <-time.After(time.Second):
+		t.Fatal("Run did not return when the drain timeout expired")
+	}
+}
+
+func TestThatTheHandlerContextHasADeadlineWhenTheTopicHasATimeout(t *testing.T) {
+	// MOCK
+	p := mock.ConsumerHooks()
+	p.Messages([]interface{}{
+		StringMessage("topicA", "message"),
+	})
+
+	// ARRANGE
+	var failure error
+	cfg := NewConfig().WithHooks(p).
+		WithAutoCommit(false).
+		WithMessageHandler("topicA", func(ctx context.Context, msg *kafka.Message) error {
+			if _, ok := ctx.Deadline(); !ok {
+				t.Error("wanted handler context with deadline")
+			}
+			<-ctx.Done()
+			return ctx.Err()
+		}, Timeout(10*time.Millisecond), OnFailure(func(ctx context.Context, f *Failure) FailureAction {
+			failure = f.Err
+			return SkipMessage
+		}))
+
+	c, _ := NewConsumer(cfg)
+
+	// ACT
+	c.Run(context.Background())
+
+	// ASSERT
+	if _, ok := failure.(ErrHandlerTimeout); !ok {
+		t.Errorf("wanted %T, got %v", ErrHandlerTimeout{}, failure)
+	}
+}
+
+func TestThatAFailedMessageIsRetriedWithRetryFailedMessages(t *testing.T) {
+	// MOCK
+	p := mock.ConsumerHooks()
+	p.Messages([]interface{}{
+		StringMessage("topicA", "message"),
+	})
+
+	// ARRANGE
+	attempts := 0
+	cfg := NewConfig().WithHooks(p).
+		WithFailurePolicy(RetryFailedMessages(3, time.Millisecond, nil)).
+		WithMessageHandler("topicA", func(ctx context.Context, msg *kafka.Message) error {
+			attempts++
+			if attempts < 3 {
+				return errors.New("failed")
+			}
+			return nil
+		})
+
+	c, _ := NewConsumer(cfg)
+
+	// ACT
+	err := c.Run(context.Background())
+
+	// ASSERT
+	if _, ok := err.(ErrHandlerFailed); ok {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if attempts != 3 {
+		t.Errorf("wanted %d attempts, got %d", 3, attempts)
+	}
+}
+
+func TestThatCancellingRunInterruptsARetryBackoff(t *testing.T) {
+	// MOCK
+	p := mock.ConsumerHooks()
+	p.Messages([]interface{}{
+		StringMessage("topicA", "message"),
+	})
+
+	// ARRANGE
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	attempts := 0
+	cfg := NewConfig().WithHooks(p).
+		WithFailurePolicy(RetryFailedMessages(3, time.Hour, nil)).
+		WithMessageHandler("topicA", func(context.Context, *kafka.Message) error {
+			attempts++
+			cancel()
+			return errors.New("failed")
+		})
+
+	c, _ := NewConsumer(cfg)
+
+	// ACT
+	result := make(chan error, 1)
+	go func() { result <- c.Run(ctx) }()
+
+	// ASSERT
+	select {
+	case err := <-result:
+		if !errors.As(err, &ErrHandlerFailed{}) {
+			t.Errorf("wanted %T, got %v", ErrHandlerFailed{}, err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Run did not return when the context was cancelled during a retry backoff")
+	}
+	if attempts != 1 {
+		t.Errorf("wanted %d attempt, got %d", 1, attempts)
+	}
+}
+
+func TestThatAFailedMessageIsSentToTheDeadLetterFunc(t *testing.T) {
+	// MOCK
+	p := mock.ConsumerHooks()
+	p.Messages([]interface{}{
+		StringMessage("topicA", "message"),
+	})
+
+	// ARRANGE
+	var deadLettered *Failure
+	cfg := NewConfig().WithHooks(p).
+		WithFailurePolicy(DeadLetterFailedMessages()).
+		WithDeadLetterFunc(func(ctx context.Context, f *Failure) error {
+			deadLettered = f
+			return nil
+		}).
+		WithMessageHandler("topicA", func(ctx context.Context, msg *kafka.Message) error {
+			return errors.New("failed")
+		})
+
+	c, _ := NewConsumer(cfg)
+
+	// ACT
+	c.Run(context.Background())
+
+	// ASSERT
+	if deadLettered == nil {
+		t.Fatal("message was not sent to the dead-letter")
+	}
+	if string(deadLettered.Message.Value) != "message" {
+		t.Errorf("wanted %q, got %q", "message", deadLettered.Message.Value)
+	}
+}
+
+func TestThatRunReturnsAnErrorIfADeadLetterIsRequiredButNotConfigured(t *testing.T) {
+	// MOCK
+	p := mock.ConsumerHooks()
+	p.Messages([]interface{}{
+		StringMessage("topicA", "message"),
+	})
+
+	// ARRANGE
+	cfg := NewConfig().WithHooks(p).
+		WithFailurePolicy(DeadLetterFailedMessages()).
+		WithMessageHandler("topicA", func(ctx context.Context, msg *kafka.Message) error {
+			return errors.New("failed")
+		})
+
+	c, _ := NewConsumer(cfg)
+
+	// ACT
+	err := c.Run(context.Background())
+
+	// ASSERT
+	if _, ok := err.(ErrDeadLetterFailed); !ok {
+		t.Errorf("wanted %T, got %T", ErrDeadLetterFailed{}, err)
+	}
+}
+
+func TestThatATopicFailurePolicyOverridesTheConfigFailurePolicy(t *testing.T) {
+	// MOCK
+	p := mock.ConsumerHooks()
+	p.Messages([]interface{}{
+		StringMessage("topicA", "message"),
+	})
+
+	// ARRANGE
+	cfg := NewConfig().WithHooks(p).
+		WithLogger(&testLogger{}).
+		WithFailurePolicy(SkipFailedMessages()).
+		WithMessageHandler("topicA", func(ctx context.Context, msg *kafka.Message) error {
+			return errors.New("failed")
+		}, OnFailure(StopOnFailure()))
+
+	c, _ := NewConsumer(cfg)
+
+	// ACT
+	err := c.Run(context.Background())
+
+	// ASSERT
+	if _, ok := err.(ErrHandlerFailed); !ok {
+		t.Errorf("wanted %T, got %T", ErrHandlerFailed{}, err)
+	}
+}
+
+func TestThatAFailedMessageIsProducedToTheDeadLetterTopicWithFailureHeaders(t *testing.T) {
+	// MOCK
+	var produced *kafka.Message
+	producerClosed := false
+
+	ph := mock.ProducerHooks()
+	ph.Funcs().Close = func(*kafka.Producer) { producerClosed = true }
+	ph.Funcs().Produce = func(p *kafka.Producer, m *kafka.Message, c chan kafka.Event) error {
+		produced = m
+		go func() { c <- m }()
+		return nil
+	}
+
+	msg := StringMessage("topicA", "message")
+	msg.TopicPartition.Partition = 2
+	msg.TopicPartition.Offset = 42
+	msg.Headers = []kafka.Header{{Key: "original", Value: []byte("header")}}
+
+	ch := mock.ConsumerHooks()
+	ch.Messages([]interface{}{msg})
+
+	// ARRANGE
+	cfg := NewConfig().WithHooks(ch).
+		WithProducerHooks(ph).
+		WithGroupId("group").
+		WithDeadLetterTopic("topicA.dlq").
+		WithFailurePolicy(RetryFailedMessages(2, time.Millisecond, DeadLetterFailedMessages())).
+		WithMessageHandler("topicA", func(ctx context.Context, msg *kafka.Message) error {
+			return errors.New("failed")
+		})
+
+	c, err := NewConsumer(cfg)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// ACT
+	c.Run(context.Background())
+
+	// ASSERT
+	if produced == nil {
+		t.Fatal("no message was produced to the dead-letter topic")
+	}
+	if *produced.TopicPartition.Topic != "topicA.dlq" {
+		t.Errorf("wanted topic %q, got %q", "topicA.dlq", *produced.TopicPartition.Topic)
+	}
+	if string(produced.Value) != "message" {
+		t.Errorf("wanted value %q, got %q", "message", produced.Value)
+	}
+
+	headers := map[string]string{}
+	for _, h := range produced.Headers {
+		headers[h.Key] = string(h.Value)
+	}
+	for k, wanted := range map[string]string{
+		"original":                    "header",
+		DeadLetterTopicHeader:         "topicA",
+		DeadLetterPartitionHeader:     "2",
+		DeadLetterOffsetHeader:        "42",
+		DeadLetterErrorHeader:         "failed",
+		DeadLetterAttemptsHeader:      "2",
+		DeadLetterConsumerGroupHeader: "group",
+	} {
+		if got := headers[k]; wanted != got {
+			t.Errorf("header %q: wanted %q, got %q", k, wanted, got)
+		}
+	}
+
+	if !producerClosed {
+		t.Error("dead-letter producer was not closed")
+	}
+}
+
+func TestThatSendingToTheDeadLetterTopicStopsWhenTheDrainTimeoutExpires(t *testing.T) {
+	// MOCK
+	ph := mock.ProducerHooks()
+	ph.Funcs().Produce = func(*kafka.Producer, *kafka.Message, chan kafka.Event) error {
+		// the message is never delivered
+		return nil
+	}
+
+	ch := mock.ConsumerHooks()
+	ch.Messages([]interface{}{
+		StringMessage("topicA", "message"),
+	})
+
+	// ARRANGE
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	cfg := NewConfig().WithHooks(ch).
+		WithProducerHooks(ph).
+		WithDrainTimeout(10*time.Millisecond).
+		WithDeadLetterTopic("topicA.dlq").
+		WithFailurePolicy(DeadLetterFailedMessages()).
+		WithMessageHandler("topicA", func(context.Context, *kafka.Message) error {
+			cancel()
+			return errors.New("failed")
+		})
+
+	c, _ := NewConsumer(cfg)
+
+	// ACT
+	result := make(chan error, 1)
+	go func() { result <- c.Run(ctx) }()
+
+	// ASSERT
+	select {
+	case err := <-result:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("wanted %v, got %v", context.Canceled, err)
+		}
+		if !errors.As(err, &ErrDeadLetterFailed{}) {
+			t.Errorf("wanted %T, got %v", ErrDeadLetterFailed{}, err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Run did not return when the drain timeout expired")
+	}
+}
+
+func TestThatTheConsumerHandlesPartitionsConcurrently(t *testing.T) {
+	// MOCK
+	msgA := StringMessage("topicA", "partition 0")
+	msgB := StringMessage("topicA", "partition 1")
+	msgB.TopicPartition.Partition = 1
+
+	p := mock.ConsumerHooks()
+	p.Messages([]interface{}{msgA, msgB, 100 * time.Millisecond})
+
+	// ARRANGE
+	started := make(chan string, 2)
+	concurrent := make(chan bool, 2)
+	cfg := NewConfig().WithHooks(p).
+		WithConcurrency(2).
+		WithMessageHandler("topicA", func(ctx context.Context, msg *kafka.Message) error {
+			started <- string(msg.Value)
+			// wait for the other message to be started
+			select {
+			case <-time.After(time.Second):
+				concurrent <- false
+			case other := <-started:
+				started <- other
+				concurrent <- true
+			}
+			return nil
+		})
+
+	c, _ := NewConsumer(cfg)
+
+	// ACT
+	c.Run(context.Background())
+
+	// ASSERT
+	if !<-concurrent || !<-concurrent {
+		t.Error("messages were not handled concurrently")
+	}
+}
+
+func TestThatTheConsumerHandlesMessagesInPartitionOrderAndCommitsWhenConcurrent(t *testing.T) {
+	// MOCK
+	msgs := []interface{}{}
+	for i := 0; i < 20; i++ {
+		msg := StringMessage("topicA", fmt.Sprintf("%d", i/2))
+		msg.TopicPartition.Partition = int32(i % 2)
+		msg.TopicPartition.Offset = kafka.Offset(i / 2)
+		msgs = append(msgs, msg)
+	}
+	msgs = append(msgs, 100*time.Millisecond)
+
+	mu := sync.Mutex{}
+	committed := map[int32]kafka.Offset{}
+
+	p := mock.ConsumerHooks()
+	p.Funcs().CommitOffset = func(c *kafka.Consumer, tpa []kafka.TopicPartition) ([]kafka.TopicPartition, error) {
+		for _, tp := range tpa {
+			committed[tp.Partition] = tp.Offset
+		}
+		return tpa, nil
+	}
+	p.Messages(msgs)
+
+	// ARRANGE
+	handled := map[int32][]kafka.Offset{}
+	cfg := NewConfig().WithHooks(p).
+		WithAutoCommit(false).
+		WithConcurrency(4).
+		WithMessageHandler("topicA", func(ctx context.Context, msg *kafka.Message) error {
+			mu.Lock()
+			defer mu.Unlock()
+			handled[msg.TopicPartition.Partition] = append(handled[msg.TopicPartition.Partition], msg.TopicPartition.Offset)
+			return nil
+		})
+
+	c, _ := NewConsumer(cfg)
+
+	// ACT
+	c.Run(context.Background())
+
+	// ASSERT
+	for partition, offsets := range handled {
+		for i, offset := range offsets {
+			if offset != kafka.Offset(i) {
+				t.Errorf("partition %d: wanted offset %d at position %d, got %d", partition, i, i, offset)
+			}
+		}
+	}
+	for _, partition := range []int32{0, 1} {
+		wanted := kafka.Offset(10)
+		got := committed[partition]
+		if wanted != got {
+			t.Errorf("partition %d: wanted committed offset %v, got %v", partition, wanted, got)
+		}
+	}
+}
+
+func TestThatTheConsumerHandlesKeysConcurrentlyAndCommitsBelowTheLowestInFlightOffset(t *testing.T) {
+	// MOCK
+	slow := StringMessage("topicA", "slow")
+	slow.Key = []byte("key-0")
+
+	// find a key handled by a different worker to the slow message
+	fast := StringMessage("topicA", "fast")
+	fast.TopicPartition.Offset = 1
+	for i := 1; keyShard(fast)%2 == keyShard(slow)%2; i++ {
+		fast.Key = []byte(fmt.Sprintf("key-%d", i))
+	}
+
+	mu := sync.Mutex{}
+	committed := []kafka.Offset{}
+
+	p := mock.ConsumerHooks()
+	p.Funcs().CommitOffset = func(c *kafka.Consumer, tpa []kafka.TopicPartition) ([]kafka.TopicPartition, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		for _, tp := range tpa {
+			committed = append(committed, tp.Offset)
+		}
+		return tpa, nil
+	}
+	p.Messages([]interface{}{slow, fast, 200 * time.Millisecond})
+
+	// ARRANGE
+	fastDone := make(chan bool, 1)
+	commitsBeforeSlowDone := []kafka.Offset{}
+	cfg := NewConfig().WithHooks(p).
+		WithAutoCommit(false).
+		WithConcurrency(2).
+		WithOrdering(KeyOrder).
+		WithMessageHandler("topicA", func(ctx context.Context, msg *kafka.Message) error {
+			if string(msg.Value) == "fast" {
+				fastDone <- true
+				return nil
+			}
+			select {
+			case <-fastDone:
+			case <-time.After(time.Second):
+				t.Error("messages with different keys were not handled concurrently")
+			}
+			// allow the consumer to commit the completed fast message (if it will)
+			time.Sleep(50 * time.Millisecond)
+			mu.Lock()
+			commitsBeforeSlowDone = append(commitsBeforeSlowDone, committed...)
+			mu.Unlock()
+			return nil
+		})
+
+	c, _ := NewConsumer(cfg)
+
+	// ACT
+	c.Run(context.Background())
+
+	// ASSERT
+	if len(commitsBeforeSlowDone) != 0 {
+		t.Errorf("wanted no commits before the slow message completed, got %v", commitsBeforeSlowDone)
+	}
+	if len(committed) == 0 || committed[len(committed)-1] != 2 {
+		t.Errorf("wanted final committed offset %d, got %v", 2, committed)
+	}
+}
+
+func TestThatTheConsumerHandlesMessagesWithTheSameKeyInOrder(t *testing.T) {
+	// MOCK
+	msgs := []interface{}{}
+	for i := 0; i < 20; i++ {
+		msg := StringMessage("topicA", fmt.Sprintf("%d", i))
+		msg.Key = []byte(fmt.Sprintf("key-%d", i%3))
+		msg.TopicPartition.Offset = kafka.Offset(i)
+		msgs = append(msgs, msg)
+	}
+	msgs = append(msgs, 100*time.Millisecond)
+
+	p := mock.ConsumerHooks()
+	p.Messages(msgs)
+
+	// ARRANGE
+	mu := sync.Mutex{}
+	handled := map[string][]kafka.Offset{}
+	cfg := NewConfig().WithHooks(p).
+		WithConcurrency(4).
+		WithOrdering(KeyOrder).
+		WithMessageHandler("topicA", func(ctx context.Context, msg *kafka.Message) error {
+			mu.Lock()
+			defer mu.Unlock()
+			handled[string(msg.Key)] = append(handled[string(msg.Key)], msg.TopicPartition.Offset)
+			return nil
+		})
+
+	c, _ := NewConsumer(cfg)
+
+	// ACT
+	c.Run(context.Background())
+
+	// ASSERT
+	for key, offsets := range handled {
+		for i := 1; i < len(offsets); i++ {
+			if offsets[i] < offsets[i-1] {
+				t.Errorf("key %s: messages handled out of order: %v", key, offsets)
+				break
+			}
+		}
+	}
+}
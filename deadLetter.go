@@ -0,0 +1,63 @@
+package kafka
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+)
+
+// Headers added to a message sent to a dead-letter topic, describing the
+// original message and the failure.
+const (
+	DeadLetterTopicHeader         = "dead-letter.topic"
+	DeadLetterPartitionHeader     = "dead-letter.partition"
+	DeadLetterOffsetHeader        = "dead-letter.offset"
+	DeadLetterTimestampHeader     = "dead-letter.timestamp"
+	DeadLetterErrorHeader         = "dead-letter.error"
+	DeadLetterAttemptsHeader      = "dead-letter.attempts"
+	DeadLetterConsumerGroupHeader = "dead-letter.consumer-group"
+)
+
+// deadLetterMessage returns a copy of a failed message to be sent to a
+// dead-letter topic.  The copy retains the key, value and headers of the
+// original message, with additional headers describing the failure.  For a
+// message received on a retry topic, the topic header identifies the topic on
+// which the message was originally received (see RetryTopicHeader).
+func deadLetterMessage(topic string, group string, f *Failure) *kafka.Message {
+	msg := f.Message
+	tp := msg.TopicPartition
+
+	headers := make([]kafka.Header, 0, len(msg.Headers)+7)
+	headers = append(headers, msg.Headers...)
+	headers = append(headers,
+		kafka.Header{Key: DeadLetterTopicHeader, Value: []byte(originalTopic(msg))},
+		kafka.Header{Key: DeadLetterPartitionHeader, Value: []byte(strconv.Itoa(int(tp.Partition)))},
+		kafka.Header{Key: DeadLetterOffsetHeader, Value: []byte(strconv.FormatInt(int64(tp.Offset), 10))},
+		kafka.Header{Key: DeadLetterTimestampHeader, Value: []byte(msg.Timestamp.UTC().Format(time.RFC3339Nano))},
+		kafka.Header{Key: DeadLetterErrorHeader, Value: []byte(f.Err.Error())},
+		kafka.Header{Key: DeadLetterAttemptsHeader, Value: []byte(strconv.Itoa(f.Attempts))},
+		kafka.Header{Key: DeadLetterConsumerGroupHeader, Value: []byte(group)},
+	)
+
+	return &kafka.Message{
+		TopicPartition: kafka.TopicPartition{
+			Topic:     &topic,
+			Partition: kafka.PartitionAny,
+		},
+		Key:     msg.Key,
+		Value:   msg.Value,
+		Headers: headers,
+	}
+}
+
+// deadLetterTopicFunc returns a DeadLetterFunc which uses the specified
+// producer to send failed messages to a dead-letter topic, waiting for each
+// message to be delivered until the context is done.
+func deadLetterTopicFunc(p *producer, topic string, group string) DeadLetterFunc {
+	return func(ctx context.Context, f *Failure) error {
+		_, err := p.MustProduceContext(ctx, deadLetterMessage(topic, group, f))
+		return err
+	}
+}
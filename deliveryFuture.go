@@ -0,0 +1,161 @@
+package kafka
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+)
+
+// DeliveryFuture is the eventual result of delivering a message produced by
+// ProduceAsync: the delivered message, or an error.  A DeliveryFuture is safe
+// for concurrent use.
+type DeliveryFuture struct {
+	mu        sync.Mutex
+	done      chan struct{}
+	msg       *kafka.Message
+	err       error
+	callbacks []func(*kafka.Message, error)
+}
+
+func newDeliveryFuture() *DeliveryFuture {
+	return &DeliveryFuture{done: make(chan struct{})}
+}
+
+// deliveredFuture returns a DeliveryFuture which is already done with the
+// specified result (e.g. for a message that could not be produced).
+func deliveredFuture(msg *kafka.Message, err error) *DeliveryFuture {
+	f := newDeliveryFuture()
+	f.complete(msg, err)
+	return f
+}
+
+// Done returns a channel which is closed when the result of delivering the
+// message is known.
+func (f *DeliveryFuture) Done() <-chan struct{} {
+	return f.done
+}
+
+// Wait waits for the result of delivering the message, returning the
+// delivered message if successful, otherwise an error (see CheckEvent).  If the
+// context is done first, the context error is returned; the message may yet be
+// delivered.
+func (f *DeliveryFuture) Wait(ctx context.Context) (*kafka.Message, error) {
+	// A result that is already known is returned even if the context is done
+	select {
+	case <-f.done:
+		return f.msg, f.err
+	default:
+	}
+
+	select {
+	case <-f.done:
+		return f.msg, f.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// OnDelivery registers a func to be called with the result of delivering the
+// message.  The func is called on the goroutine which receives the delivery
+// event or, if the result is already known, immediately.  Funcs are called in
+// the order in which they were registered.
+func (f *DeliveryFuture) OnDelivery(fn func(*kafka.Message, error)) {
+	f.mu.Lock()
+	select {
+	case <-f.done:
+		f.mu.Unlock()
+		fn(f.msg, f.err)
+	default:
+		f.callbacks = append(f.callbacks, fn)
+		f.mu.Unlock()
+	}
+}
+
+// complete records the result of delivering the message and calls any
+// registered funcs.
+func (f *DeliveryFuture) complete(msg *kafka.Message, err error) {
+	f.mu.Lock()
+	f.msg, f.err = msg, err
+	close(f.done)
+	callbacks := f.callbacks
+	f.callbacks = nil
+	f.mu.Unlock()
+
+	for _, fn := range callbacks {
+		fn(msg, err)
+	}
+}
+
+// ProduceAsync produces a message without waiting for a delivery event,
+// returning a DeliveryFuture for the result of delivering the message.  This
+// allows many messages to be produced before waiting for (some of) them to be
+// delivered.
+//
+// Any middleware configured for the producer is applied to the message before
+// it is produced.  If the message cannot be produced (e.g. a middleware
+// returns an error) the DeliveryFuture is already done with the error.  If a
+// middleware returns a nil message, the message is not produced and the
+// DeliveryFuture is done with a nil message and error.
+//
+// If no delivery event is received within any delivery timeout configured for
+// the producer (see WithDeliveryTimeout), the DeliveryFuture is done with an
+// ErrDeliveryTimeout.  If the producer is closed before a delivery event is
+// received, the DeliveryFuture is done with ErrProducerClosed.
+func (p *producer) ProduceAsync(msg *kafka.Message) *DeliveryFuture {
+	msg, err := p.prepare(msg)
+	if err != nil || msg == nil {
+		return deliveredFuture(nil, err)
+	}
+	return p.produceAsync(msg)
+}
+
+// prepare checks that a message has a topic and applies any middleware
+// configured for the producer, returning the message to be produced, or nil if
+// a middleware returned a nil message.
+func (p *producer) prepare(msg *kafka.Message) (*kafka.Message, error) {
+	if msg.TopicPartition.Topic == nil || *msg.TopicPartition.Topic == "" {
+		return nil, &ErrNoTopicId{message: "message has no topic id"}
+	}
+	return p.middleware.apply(msg)
+}
+
+// produceAsync produces a message to which any middleware has been applied
+// (see prepare), returning a DeliveryFuture for the result of delivering the
+// message.
+func (p *producer) produceAsync(msg *kafka.Message) *DeliveryFuture {
+	// The delivery event may be sent to the channel after the timeout has
+	// expired, so the channel is buffered (and is not closed)
+	dc := make(chan kafka.Event, 1)
+
+	if err := p.hooks.Produce(p.producer, msg, dc); err != nil {
+		return deliveredFuture(nil, err)
+	}
+
+	f := newDeliveryFuture()
+	go func() {
+		var timeout <-chan time.Time
+		if d := p.config.deliveryTimeout; d > 0 {
+			timer := time.NewTimer(d)
+			defer timer.Stop()
+			timeout = timer.C
+		}
+
+		select {
+		case event := <-dc:
+			f.complete(CheckEvent(event))
+		case <-timeout:
+			f.complete(nil, ErrDeliveryTimeout{TopicPartition: msg.TopicPartition, Timeout: p.config.deliveryTimeout})
+		case <-p.closed:
+			// A delivery event may have been sent as the producer was closed
+			select {
+			case event := <-dc:
+				f.complete(CheckEvent(event))
+			default:
+				f.complete(nil, ErrProducerClosed)
+			}
+		}
+	}()
+	return f
+}
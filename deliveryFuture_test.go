@@ -0,0 +1,185 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+
+	"github.com/deltics/go-kafka/mock"
+)
+
+// asyncHooks returns producer hooks which record the delivery channel for
+// each message produced, by message value, so that delivery events may be
+// sent for specific messages.
+func asyncHooks(channels map[string]chan kafka.Event) mock.MockProducerProvider {
+	hk := mock.ProducerHooks()
+	hk.Funcs().Produce = func(p *kafka.Producer, m *kafka.Message, c chan kafka.Event) error {
+		channels[string(m.Value)] = c
+		return nil
+	}
+	return hk
+}
+
+func TestThatProduceAsyncReturnsAFutureForEachMessage(t *testing.T) {
+	// ARRANGE
+	channels := map[string]chan kafka.Event{}
+	p, _ := NewProducer(NewConfig().WithHooks(asyncHooks(channels)))
+
+	a := p.ProduceAsync(StringMessage("topic", "a"))
+	b := p.ProduceAsync(StringMessage("topic", "b"))
+
+	// ACT
+	delivered := StringMessage("topic", "b")
+	delivered.TopicPartition.Offset = 1
+	channels["b"] <- delivered
+
+	got, err := b.Wait(context.Background())
+
+	// ASSERT
+	t.Run("returns the delivered message", func(t *testing.T) {
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+		if got != delivered {
+			t.Errorf("wanted %v, got %v", delivered, got)
+		}
+	})
+
+	t.Run("other messages are not done", func(t *testing.T) {
+		select {
+		case <-a.Done():
+			t.Error("future for an undelivered message is done")
+		default:
+		}
+	})
+}
+
+func TestThatProduceAsyncReturnsADoneFutureIfTheMessageCannotBeProduced(t *testing.T) {
+	// ARRANGE
+	produceErr := errors.New("produce error")
+	hk := mock.ProducerHooks()
+	hk.Funcs().Produce = func(*kafka.Producer, *kafka.Message, chan kafka.Event) error {
+		return produceErr
+	}
+	p, _ := NewProducer(NewConfig().WithHooks(hk))
+
+	// ACT
+	f := p.ProduceAsync(StringMessage("topic", "message"))
+
+	// ASSERT
+	select {
+	case <-f.Done():
+	default:
+		t.Fatal("future is not done")
+	}
+	if _, err := f.Wait(context.Background()); err != produceErr {
+		t.Errorf("wanted %v, got %v", produceErr, err)
+	}
+}
+
+func TestThatADeliveryFutureIsDoneWithAnErrorIfTheDeliveryTimeoutExpires(t *testing.T) {
+	// ARRANGE
+	p, _ := NewProducer(NewConfig().WithHooks(mock.ProducerHooks()).WithDeliveryTimeout(10 * time.Millisecond))
+
+	// ACT
+	_, err := p.ProduceAsync(StringMessage("topic", "message")).Wait(context.Background())
+
+	// ASSERT
+	if !errors.As(err, &ErrDeliveryTimeout{}) {
+		t.Errorf("wanted %T, got %v", ErrDeliveryTimeout{}, err)
+	}
+}
+
+func TestThatPendingDeliveryFuturesAreDoneWhenTheProducerIsClosed(t *testing.T) {
+	// ARRANGE
+	goroutines := runtime.NumGoroutine()
+	p, _ := NewProducer(NewConfig().WithHooks(mock.ProducerHooks()))
+	futures := []*DeliveryFuture{
+		p.ProduceAsync(StringMessage("topic", "a")),
+		p.ProduceAsync(StringMessage("topic", "b")),
+	}
+
+	// ACT
+	p.Close()
+
+	// the goroutine waiting for the delivery of each message exits when
+	// its future is done
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > goroutines && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	leaked := runtime.NumGoroutine() - goroutines
+
+	// ASSERT
+	t.Run("futures are done with ErrProducerClosed", func(t *testing.T) {
+		for _, f := range futures {
+			select {
+			case <-f.Done():
+				if _, err := f.Wait(context.Background()); !errors.Is(err, ErrProducerClosed) {
+					t.Errorf("wanted %v, got %v", ErrProducerClosed, err)
+				}
+			default:
+				t.Error("future is not done")
+			}
+		}
+	})
+
+	t.Run("no goroutines are leaked", func(t *testing.T) {
+		if leaked > 0 {
+			t.Errorf("wanted no leaked goroutines, got %d", leaked)
+		}
+	})
+}
+
+func TestThatDeliveryFutureWaitReturnsTheContextErrorIfTheContextIsDone(t *testing.T) {
+	// ARRANGE
+	p, _ := NewProducer(NewConfig().WithHooks(mock.ProducerHooks()))
+	f := p.ProduceAsync(StringMessage("topic", "message"))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	// ACT
+	_, err := f.Wait(ctx)
+
+	// ASSERT
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("wanted %v, got %v", context.Canceled, err)
+	}
+}
+
+func TestThatADeliveryFutureCallsRegisteredFuncs(t *testing.T) {
+	// ARRANGE
+	channels := map[string]chan kafka.Event{}
+	p, _ := NewProducer(NewConfig().WithHooks(asyncHooks(channels)))
+	f := p.ProduceAsync(StringMessage("topic", "message"))
+
+	calls := make(chan string, 3)
+	f.OnDelivery(func(msg *kafka.Message, err error) { calls <- "1:" + string(msg.Value) })
+	f.OnDelivery(func(msg *kafka.Message, err error) { calls <- "2:" + string(msg.Value) })
+
+	// ACT
+	channels["message"] <- StringMessage("topic", "delivered")
+	f.Wait(context.Background())
+	f.OnDelivery(func(msg *kafka.Message, err error) { calls <- "3:" + string(msg.Value) })
+
+	// ASSERT
+	got := []string{}
+	for i := 0; i < 3; i++ {
+		select {
+		case c := <-calls:
+			got = append(got, c)
+		case <-time.After(time.Second):
+			t.Fatalf("wanted 3 calls, got %v", got)
+		}
+	}
+	wanted := "[1:delivered 2:delivered 3:delivered]"
+	if fmt.Sprintf("%v", got) != wanted {
+		t.Errorf("wanted %s, got %v", wanted, got)
+	}
+}
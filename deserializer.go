@@ -0,0 +1,75 @@
+package kafka
+
+import (
+	"encoding/json"
+	"reflect"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// Deserializer decodes the value of a message received on a topic into a value
+// of type T.
+type Deserializer[T any] func(topic string, data []byte) (T, error)
+
+// BytesDeserializer returns a Deserializer which returns the value of a
+// message unchanged.
+func BytesDeserializer() Deserializer[[]byte] {
+	return func(_ string, data []byte) ([]byte, error) {
+		return data, nil
+	}
+}
+
+// StringDeserializer returns a Deserializer which decodes the value of a
+// message as a string.
+func StringDeserializer() Deserializer[string] {
+	return func(_ string, data []byte) (string, error) {
+		return string(data), nil
+	}
+}
+
+// JSONDeserializer returns a Deserializer which decodes the value of a message
+// as JSON, using json.Unmarshal.
+func JSONDeserializer[T any]() Deserializer[T] {
+	return func(_ string, data []byte) (T, error) {
+		var v T
+		err := json.Unmarshal(data, &v)
+		return v, err
+	}
+}
+
+// ProtobufDeserializer returns a Deserializer which decodes the value of a
+// message as a protobuf message of type T (a pointer to a generated message
+// type, e.g. *pb.Order).  An ErrInvalidProtobufType error is returned if T is
+// not a pointer to a generated message type.
+func ProtobufDeserializer[T proto.Message]() (Deserializer[T], error) {
+	mt, err := protobufMessageType[T]()
+	if err != nil {
+		return nil, err
+	}
+
+	return func(_ string, data []byte) (T, error) {
+		v := mt.New().Interface().(T)
+		if err := proto.Unmarshal(data, v); err != nil {
+			var zero T
+			return zero, err
+		}
+		return v, nil
+	}, nil
+}
+
+// protobufMessageType returns the message type of T, or ErrInvalidProtobufType
+// if T is not a pointer to a generated message type.
+func protobufMessageType[T proto.Message]() (mt protoreflect.MessageType, err error) {
+	// ProtoReflect() may be called on a nil pointer of a generated message
+	// type to obtain the message type; for any other T (e.g. an interface) the
+	// zero value cannot reflect, and the call panics
+	defer func() {
+		if recover() != nil {
+			mt, err = nil, ErrInvalidProtobufType{Type: reflect.TypeOf((*T)(nil)).Elem().String()}
+		}
+	}()
+
+	var zero T
+	return zero.ProtoReflect().Type(), nil
+}
@@ -0,0 +1,109 @@
+package kafka
+
+import (
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func TestThatBytesDeserializerReturnsTheValueUnchanged(t *testing.T) {
+	// ACT
+	got, err := BytesDeserializer()("topic", []byte("value"))
+
+	// ASSERT
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if string(got) != "value" {
+		t.Errorf("wanted %q, got %q", "value", got)
+	}
+}
+
+func TestThatStringDeserializerDecodesTheValueAsAString(t *testing.T) {
+	// ACT
+	got, err := StringDeserializer()("topic", []byte("value"))
+
+	// ASSERT
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if got != "value" {
+		t.Errorf("wanted %q, got %q", "value", got)
+	}
+}
+
+func TestThatJSONDeserializerDecodesTheValue(t *testing.T) {
+	type order struct {
+		Id  string `json:"id"`
+		Qty int    `json:"qty"`
+	}
+
+	t.Run("decodes valid json", func(t *testing.T) {
+		// ACT
+		got, err := JSONDeserializer[order]()("topic", []byte(`{"id":"a","qty":2}`))
+
+		// ASSERT
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+		wanted := order{Id: "a", Qty: 2}
+		if got != wanted {
+			t.Errorf("wanted %v, got %v", wanted, got)
+		}
+	})
+
+	t.Run("returns error for invalid json", func(t *testing.T) {
+		// ACT
+		_, err := JSONDeserializer[order]()("topic", []byte(`{"id":`))
+
+		// ASSERT
+		if err == nil {
+			t.Error("wanted error, got nil")
+		}
+	})
+}
+
+func TestThatProtobufDeserializerDecodesTheValue(t *testing.T) {
+	t.Run("decodes valid protobuf", func(t *testing.T) {
+		// ARRANGE
+		data, _ := proto.Marshal(wrapperspb.String("value"))
+
+		des, _ := ProtobufDeserializer[*wrapperspb.StringValue]()
+
+		// ACT
+		got, err := des("topic", data)
+
+		// ASSERT
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+		if got.GetValue() != "value" {
+			t.Errorf("wanted %q, got %q", "value", got.GetValue())
+		}
+	})
+
+	t.Run("returns error for invalid protobuf", func(t *testing.T) {
+		// ARRANGE
+		des, _ := ProtobufDeserializer[*wrapperspb.StringValue]()
+
+		// ACT
+		_, err := des("topic", []byte{0xff})
+
+		// ASSERT
+		if err == nil {
+			t.Error("wanted error, got nil")
+		}
+	})
+}
+
+func TestThatProtobufDeserializerReturnsAnErrorForAnInterfaceType(t *testing.T) {
+	// ACT
+	des, err := ProtobufDeserializer[proto.Message]()
+
+	// ASSERT
+	wanted := ErrInvalidProtobufType{Type: "protoreflect.ProtoMessage"}
+	if des != nil || err != wanted {
+		t.Errorf("wanted %v, got %v", wanted, err)
+	}
+}
@@ -0,0 +1,44 @@
+package kafka
+
+import (
+	"context"
+	"time"
+)
+
+// detachedContext is a context that carries the values of a parent context
+// but is never cancelled and has no deadline.
+type detachedContext struct {
+	parent context.Context
+}
+
+func (detachedContext) Deadline() (time.Time, bool)         { return time.Time{}, false }
+func (detachedContext) Done() <-chan struct{}               { return nil }
+func (detachedContext) Err() error                          { return nil }
+func (d detachedContext) Value(key interface{}) interface{} { return d.parent.Value(key) }
+
+// drainContext returns a context for use by message handlers which is not
+// cancelled when the specified context is cancelled.  Instead, it is cancelled
+// when the specified timeout has elapsed after the context is cancelled.
+//
+// If the timeout is zero the returned context is only cancelled by calling
+// the returned cancel func.
+func drainContext(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	dctx, cancel := context.WithCancel(detachedContext{parent: ctx})
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			if timeout <= 0 {
+				return
+			}
+			select {
+			case <-time.After(timeout):
+				cancel()
+			case <-dctx.Done():
+			}
+		case <-dctx.Done():
+		}
+	}()
+
+	return dctx, cancel
+}
@@ -1,7 +1,10 @@
 package kafka
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"time"
 
 	"github.com/confluentinc/confluent-kafka-go/kafka"
 )
@@ -21,3 +24,235 @@ type ErrNoTopicId struct {
 func (e ErrNoTopicId) Error() string {
 	return "message had no topic id"
 }
+
+// ErrDeliveryTimeout is returned by MustProduce (or MustProduceContext) when
+// no delivery event is received for a message within the delivery timeout
+// configured for the producer (see WithDeliveryTimeout).  It is an ErrTimeOut.
+type ErrDeliveryTimeout struct {
+	TopicPartition kafka.TopicPartition
+	Timeout        time.Duration
+}
+
+func (e ErrDeliveryTimeout) Error() string {
+	return fmt.Sprintf("no delivery event for message to %s after %v", e.TopicPartition, e.Timeout)
+}
+
+func (e ErrDeliveryTimeout) Unwrap() error {
+	return ErrTimeOut{}
+}
+
+// ErrBatchFailed is returned by BatchResult.Err() if any of the messages
+// produced by ProduceBatch were not delivered.  Err is the error for the first
+// message that was not delivered.
+type ErrBatchFailed struct {
+	Messages int
+	Failed   int
+	Err      error
+}
+
+func (e ErrBatchFailed) Error() string {
+	return fmt.Sprintf("%d of %d messages not delivered: %v", e.Failed, e.Messages, e.Err)
+}
+
+func (e ErrBatchFailed) Unwrap() error {
+	return e.Err
+}
+
+// ErrConsumerNotRunning is returned by a Consumer method which requires the
+// consumer to be running (e.g. Seek) if Run has returned.
+var ErrConsumerNotRunning = errors.New("consumer is not running")
+
+// ErrProducerClosed is the error of a DeliveryFuture which was not done when
+// the producer was closed; the message may or may not have been delivered.
+var ErrProducerClosed = errors.New("producer is closed")
+
+// ErrNoHandler is returned by NewConsumer if the assignment configured for the
+// consumer (see WithAssignment) includes a partition of a topic for which no
+// handler is configured.
+type ErrNoHandler struct {
+	Topic string
+}
+
+func (e ErrNoHandler) Error() string {
+	return fmt.Sprintf("no handler for topic %s", e.Topic)
+}
+
+// ErrInvalidTopicPattern is returned by NewConsumer if a topic pattern for
+// which a handler is configured is not a valid regular expression.
+type ErrInvalidTopicPattern struct {
+	Pattern string
+	Err     error
+}
+
+func (e ErrInvalidTopicPattern) Error() string {
+	return fmt.Sprintf("invalid topic pattern %q: %v", e.Pattern, e.Err)
+}
+
+func (e ErrInvalidTopicPattern) Unwrap() error {
+	return e.Err
+}
+
+// ErrTransactionClosed is returned when a message is produced in a Transaction
+// after the func to which the Transaction was passed has returned (e.g. by a
+// handler which continues after being timed out).
+var ErrTransactionClosed = errors.New("transaction is closed")
+
+// ErrNotTransactional is returned by a transactional handler (see
+// WithTransactionalHandler) if the Consumer is not transactional.
+var ErrNotTransactional = errors.New("consumer is not transactional: no transactional id configured")
+
+// ErrHandlerFailed is returned by Consumer.Run() when a message handler
+// returns an error and the FailurePolicy for the topic stops the consumer.
+type ErrHandlerFailed struct {
+	Failure *Failure
+}
+
+func (e ErrHandlerFailed) Error() string {
+	return fmt.Sprintf("handler failed for message %s (attempt %d): %v",
+		e.Failure.Message.TopicPartition, e.Failure.Attempts, e.Failure.Err)
+}
+
+func (e ErrHandlerFailed) Unwrap() error {
+	return e.Failure.Err
+}
+
+// errNoDeadLetter is the error reported by an ErrDeadLetterFailed if a
+// FailurePolicy returns DeadLetterMessage but no dead-letter is configured.
+var errNoDeadLetter = errors.New("no dead-letter configured")
+
+// ErrDeadLetterFailed is returned by Consumer.Run() when a failed message
+// could not be sent to the dead-letter.
+type ErrDeadLetterFailed struct {
+	Failure *Failure
+	Err     error
+}
+
+func (e ErrDeadLetterFailed) Error() string {
+	return fmt.Sprintf("dead-letter failed for message %s: %v (handler error: %v)",
+		e.Failure.Message.TopicPartition, e.Err, e.Failure.Err)
+}
+
+func (e ErrDeadLetterFailed) Unwrap() error {
+	return e.Err
+}
+
+// ErrRetryFailed is returned by Consumer.Run() when a failed message could not
+// be forwarded to a retry topic.
+type ErrRetryFailed struct {
+	Failure *Failure
+	Topic   string
+	Err     error
+}
+
+func (e ErrRetryFailed) Error() string {
+	return fmt.Sprintf("retry failed for message %s: could not forward to %s: %v (handler error: %v)",
+		e.Failure.Message.TopicPartition, e.Topic, e.Err, e.Failure.Err)
+}
+
+func (e ErrRetryFailed) Unwrap() error {
+	return e.Err
+}
+
+// ErrHandlerPanic is the error reported for a message when a handler (or
+// handler middleware) panics.  The panic is recovered and the error is
+// subject to the FailurePolicy for the topic, as for any other error returned
+// by the handler.
+//
+// For a BatchMessageHandler, TopicPartition identifies the first message in
+// the batch.
+type ErrHandlerPanic struct {
+	TopicPartition kafka.TopicPartition
+	Value          interface{} // the value passed to panic()
+	Stack          []byte      // the stack of the panicking goroutine
+}
+
+func (e ErrHandlerPanic) Error() string {
+	return fmt.Sprintf("handler panicked for message %s: %v", e.TopicPartition, e.Value)
+}
+
+// Unwrap returns the value passed to panic(), if it is an error.
+func (e ErrHandlerPanic) Unwrap() error {
+	err, _ := e.Value.(error)
+	return err
+}
+
+// ErrHandlerTimeout is the error reported for a message when the handler for
+// a topic with a Timeout does not handle the message within the time allowed.
+// The error is subject to the FailurePolicy for the topic, as for any other
+// error returned by the handler, but the handler may still be running when the
+// policy is applied: the handler is abandoned, not stopped (see Timeout).
+//
+// For a BatchMessageHandler, TopicPartition identifies the first message in
+// the batch.
+type ErrHandlerTimeout struct {
+	TopicPartition kafka.TopicPartition
+	Timeout        time.Duration
+}
+
+func (e ErrHandlerTimeout) Error() string {
+	return fmt.Sprintf("handler timed out after %v for message %s", e.Timeout, e.TopicPartition)
+}
+
+// Unwrap returns context.DeadlineExceeded.
+func (e ErrHandlerTimeout) Unwrap() error {
+	return context.DeadlineExceeded
+}
+
+// ErrDeserializationFailed is the error reported for a message that could not
+// be decoded by the Deserializer of a TypedMessageHandler.  The handler is not
+// called; the error is subject to the OnDeserializationFailure policy for the
+// topic.
+type ErrDeserializationFailed struct {
+	TopicPartition kafka.TopicPartition
+	Err            error
+}
+
+func (e ErrDeserializationFailed) Error() string {
+	return fmt.Sprintf("deserialization failed for message %s: %v", e.TopicPartition, e.Err)
+}
+
+func (e ErrDeserializationFailed) Unwrap() error {
+	return e.Err
+}
+
+// ErrSerializationFailed is returned by a TypedProducer when the key or value
+// of a message could not be encoded by the Serializer of the producer.
+type ErrSerializationFailed struct {
+	Topic string
+	Field string // "key" or "value"
+	Err   error
+}
+
+func (e ErrSerializationFailed) Error() string {
+	return fmt.Sprintf("serialization of %s failed for topic %s: %v", e.Field, e.Topic, e.Err)
+}
+
+func (e ErrSerializationFailed) Unwrap() error {
+	return e.Err
+}
+
+// ErrInvalidProtobufType is returned by a protobuf Serializer or Deserializer
+// constructor if T is not a pointer to a generated message type (e.g. if T is
+// an interface such as proto.Message).
+type ErrInvalidProtobufType struct {
+	Type string
+}
+
+func (e ErrInvalidProtobufType) Error() string {
+	return fmt.Sprintf("invalid protobuf type %s: not a pointer to a generated message type", e.Type)
+}
+
+// errTransactionFailed is returned by Consumer.call if messages were handled
+// successfully but the transaction in which they were handled could not be
+// committed.
+type errTransactionFailed struct {
+	err error
+}
+
+func (e errTransactionFailed) Error() string {
+	return fmt.Sprintf("transaction failed: %v", e.err)
+}
+
+func (e errTransactionFailed) Unwrap() error {
+	return e.err
+}
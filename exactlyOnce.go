@@ -0,0 +1,186 @@
+package kafka
+
+import (
+	"context"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+)
+
+// redelivery records the attempts made to handle a message whose transaction
+// failed, at which its partition was rewound so that it is consumed again.
+type redelivery struct {
+	offset   kafka.Offset
+	attempts int
+}
+
+// transactionKey is the context key of the Transaction in which a message is
+// handled by a transactional Consumer.
+type transactionKey struct{}
+
+// transactionalHandler returns a MessageHandler which calls a
+// TransactionalHandler with the Transaction in which the message is handled.
+func transactionalHandler(fn TransactionalHandler) MessageHandler {
+	return func(ctx context.Context, msg *kafka.Message) error {
+		tx, ok := ctx.Value(transactionKey{}).(*Transaction)
+		if !ok {
+			return ErrNotTransactional
+		}
+		return fn(ctx, msg, tx)
+	}
+}
+
+// newTransactionalProducer creates the transactional producer of an
+// exactly-once consumer, in which messages are handled.
+func (c *Consumer) newTransactionalProducer() (*producer, error) {
+	cfg := c.config.copy()
+	cfg.hooks = nil
+	cfg.middleware = middlewareChain{}
+	return NewProducer(cfg)
+}
+
+// call calls a handler with messages.  If the consumer is transactional, the
+// handler is called in a transaction, to which the offsets of the messages are
+// sent, so that any messages produced by the handler in the transaction are
+// delivered (and the messages are committed as consumed) only if the
+// transaction is committed.
+//
+// If the handler fails the transaction is aborted and the error from the
+// handler returned.  If the handler succeeds but the transaction cannot be
+// committed, an errTransactionFailed is returned.
+func (c *Consumer) call(ctx context.Context, h messageHandler, msgs []*kafka.Message) error {
+	if c.transactions == nil {
+		return h.call(ctx, msgs)
+	}
+
+	offsets := nextOffsets(msgs)
+
+	var herr error
+	err := c.transactions.Transact(ctx, func(tx *Transaction) error {
+		if herr = h.call(context.WithValue(ctx, transactionKey{}, tx), msgs); herr != nil {
+			return herr
+		}
+		return c.sendOffsets(ctx, tx, offsets)
+	})
+	switch {
+	case err != nil && IsFatalError(err):
+		return errTransactionFailed{err}
+	case herr != nil:
+		return herr
+	case err != nil:
+		return errTransactionFailed{err}
+	}
+
+	c.offsets.committed(offsets)
+	return nil
+}
+
+// sendOffsets sends offsets to a transaction with the current metadata of the
+// consumer group.  The metadata is obtained for each transaction since it
+// changes with each rebalance.
+func (c *Consumer) sendOffsets(ctx context.Context, tx *Transaction, offsets []kafka.TopicPartition) error {
+	md, err := c.hooks.GetConsumerGroupMetadata(c.consumer)
+	if err != nil {
+		return err
+	}
+	return tx.SendOffsets(ctx, offsets, md)
+}
+
+// commitTransaction commits offsets in a transaction with no messages (e.g.
+// the offsets of messages skipped by a FailurePolicy).  If the offsets cannot
+// be committed the failure is logged and the offsets remain uncommitted, to be
+// committed with the next transaction.  Only a fatal error is returned.
+//
+// The transaction is committed with the drain context, so that a commit when
+// the consumer stops is abandoned once the drain timeout has expired.
+func (c *Consumer) commitTransaction(tpa []kafka.TopicPartition) error {
+	ctx := c.drainCtx
+	err := c.transactions.Transact(ctx, func(tx *Transaction) error {
+		return c.sendOffsets(ctx, tx, tpa)
+	})
+	switch {
+	case err == nil:
+		c.offsets.committed(tpa)
+		return nil
+
+	case IsFatalError(err):
+		return err
+
+	default:
+		c.logger.Printf("commit of offsets %v failed: %v", tpa, err)
+		return nil
+	}
+}
+
+// priorAttempts returns the number of attempts made to handle a message
+// before it was consumed: those made before it was forwarded to a retry topic
+// or, if it is consumed again after a failed transaction, those made before
+// the transaction failed.
+func (c *Consumer) priorAttempts(msg *kafka.Message) int {
+	// redeliveries are recorded only by an exactly-once consumer, which does
+	// not handle messages concurrently
+	if c.transactions != nil {
+		p := partitionOf(msg.TopicPartition)
+		r, ok := c.redeliveries[p]
+		delete(c.redeliveries, p)
+		if ok && r.offset == msg.TopicPartition.Offset {
+			return r.attempts
+		}
+	}
+	return retryAttempts(msg)
+}
+
+// reconsume positions the partitions of messages whose transaction failed
+// (and was aborted) so that the messages are consumed again, when the
+// FailurePolicy retries the failure; the outcome of handling the messages was
+// discarded with the transaction.  The attempts made are recorded, so that
+// they count toward the FailurePolicy when the messages are consumed again.
+//
+// If the transaction failed because the partitions have been reassigned to
+// another consumer in the group, the messages are consumed by that consumer
+// from the offsets committed by this consumer.
+func (c *Consumer) reconsume(f *Failure, msgs []*kafka.Message) error {
+	c.logger.Printf("message %s will be consumed again after %d attempt(s): %v", msgs[0].TopicPartition, f.Attempts, f.Err)
+
+	first := map[partition]kafka.Offset{}
+	for _, msg := range msgs {
+		p := partitionOf(msg.TopicPartition)
+		if offset, ok := first[p]; !ok || msg.TopicPartition.Offset < offset {
+			first[p] = msg.TopicPartition.Offset
+		}
+	}
+
+	partitions := map[partition]bool{}
+	for p := range first {
+		partitions[p] = true
+	}
+	c.discardBatched(partitions)
+
+	for p, offset := range first {
+		c.offsets.remove(p)
+		if err := c.hooks.Seek(c.consumer, p.topicPartition(offset), 0); err != nil {
+			return err
+		}
+	}
+
+	c.redeliveries[partitionOf(msgs[0].TopicPartition)] = redelivery{offset: msgs[0].TopicPartition.Offset, attempts: f.Attempts}
+	return nil
+}
+
+// nextOffsets returns the offsets to be committed once the specified messages
+// have been consumed: for each partition, the offset following the highest
+// offset of the messages on that partition.
+func nextOffsets(msgs []*kafka.Message) []kafka.TopicPartition {
+	next := map[partition]kafka.Offset{}
+	for _, msg := range msgs {
+		p := partitionOf(msg.TopicPartition)
+		if offset, ok := next[p]; !ok || msg.TopicPartition.Offset >= offset {
+			next[p] = msg.TopicPartition.Offset + 1
+		}
+	}
+
+	tpa := make([]kafka.TopicPartition, 0, len(next))
+	for p, offset := range next {
+		tpa = append(tpa, p.topicPartition(offset))
+	}
+	return tpa
+}
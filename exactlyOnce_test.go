@@ -0,0 +1,376 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+
+	"github.com/deltics/go-kafka/mock"
+)
+
+// exactlyOnceHooks returns producer hooks which record the transactional
+// operations called, in order, including messages produced and offsets sent
+// to transactions.
+func exactlyOnceHooks(calls *[]string) mock.MockProducerProvider {
+	hk := transactionHooks(calls)
+	hk.Funcs().Produce = func(_ *kafka.Producer, msg *kafka.Message, _ chan kafka.Event) error {
+		*calls = append(*calls, "produce "+string(msg.Value))
+		return nil
+	}
+	hk.Funcs().SendOffsetsToTransaction = func(_ *kafka.Producer, _ context.Context, offsets []kafka.TopicPartition, _ *kafka.ConsumerGroupMetadata) error {
+		*calls = append(*calls, fmt.Sprintf("offsets %v", offsets))
+		return nil
+	}
+	return hk
+}
+
+// forward is a TransactionalHandler which produces the value of each message
+// to the "output" topic in the transaction.
+func forward(ctx context.Context, msg *kafka.Message, tx *Transaction) error {
+	topic := "output"
+	return tx.Produce(&kafka.Message{
+		TopicPartition: kafka.TopicPartition{Topic: &topic, Partition: kafka.PartitionAny},
+		Value:          msg.Value,
+	})
+}
+
+func TestThatAnExactlyOnceConsumerHandlesMessagesInTransactions(t *testing.T) {
+	// MOCK
+	committed := false
+	ch := mock.ConsumerHooks()
+	ch.Funcs().CommitOffset = func(c *kafka.Consumer, tpa []kafka.TopicPartition) ([]kafka.TopicPartition, error) {
+		committed = true
+		return tpa, nil
+	}
+	ch.Messages([]interface{}{
+		partitionMessage("topicA", 0, 1),
+		partitionMessage("topicA", 0, 2),
+	})
+
+	// ARRANGE
+	calls := []string{}
+	cfg := NewConfig().WithHooks(ch).
+		WithProducerHooks(exactlyOnceHooks(&calls)).
+		WithTransactionalId("txn").
+		WithTransactionalHandler("topicA", forward)
+
+	c, _ := NewConsumer(cfg)
+
+	// ACT
+	c.Run(context.Background())
+
+	// ASSERT
+	wanted := "[init" +
+		" begin produce 0:1 offsets [topicA[0]@2] commit" +
+		" begin produce 0:2 offsets [topicA[0]@3] commit]"
+	if got := fmt.Sprintf("%v", calls); got != wanted {
+		t.Errorf("wanted %s, got %s", wanted, got)
+	}
+	if committed {
+		t.Error("offsets were committed by the consumer")
+	}
+}
+
+func TestThatAnExactlyOnceConsumerCommitsTheOffsetsOfASkippedMessageInATransaction(t *testing.T) {
+	// MOCK
+	ch := mock.ConsumerHooks()
+	ch.Messages([]interface{}{
+		partitionMessage("topicA", 0, 1),
+	})
+
+	// ARRANGE
+	calls := []string{}
+	cfg := NewConfig().WithHooks(ch).
+		WithProducerHooks(exactlyOnceHooks(&calls)).
+		WithTransactionalId("txn").
+		WithTransactionalHandler("topicA", func(ctx context.Context, msg *kafka.Message, tx *Transaction) error {
+			forward(ctx, msg, tx)
+			return errors.New("failed")
+		})
+
+	c, _ := NewConsumer(cfg)
+
+	// ACT
+	c.Run(context.Background())
+
+	// ASSERT
+	wanted := "[init" +
+		" begin produce 0:1 abort" +
+		" begin offsets [topicA[0]@2] commit]"
+	if got := fmt.Sprintf("%v", calls); got != wanted {
+		t.Errorf("wanted %s, got %s", wanted, got)
+	}
+}
+
+func TestThatAMessageIsConsumedAgainWhenItsTransactionCannotBeCommitted(t *testing.T) {
+	// MOCK
+	sought := []string{}
+	ch := mock.ConsumerHooks()
+	ch.Funcs().Seek = func(c *kafka.Consumer, tp kafka.TopicPartition, timeoutMs int) error {
+		sought = append(sought, tp.String())
+		return nil
+	}
+	// the message at offset 1 is consumed again after the seek
+	ch.Messages([]interface{}{
+		partitionMessage("topicA", 0, 1),
+		partitionMessage("topicA", 0, 1),
+		partitionMessage("topicA", 0, 2),
+	})
+
+	calls := []string{}
+	ph := exactlyOnceHooks(&calls)
+	ph.Funcs().CommitTransaction = func(*kafka.Producer, context.Context) error {
+		calls = append(calls, "commit")
+		return mock.AbortableError()
+	}
+
+	// ARRANGE
+	handled := 0
+	attempts := []int{}
+	retry := RetryFailedMessages(2, time.Millisecond, SkipFailedMessages())
+	cfg := NewConfig().WithHooks(ch).
+		WithProducerHooks(ph).
+		WithTransactionalId("txn").
+		WithFailurePolicy(func(ctx context.Context, f *Failure) FailureAction {
+			attempts = append(attempts, f.Attempts)
+			return retry(ctx, f)
+		}).
+		WithTransactionalHandler("topicA", func(ctx context.Context, msg *kafka.Message, tx *Transaction) error {
+			handled++
+			return forward(ctx, msg, tx)
+		})
+
+	c, _ := NewConsumer(cfg)
+
+	// ACT
+	err := c.Run(context.Background())
+
+	// ASSERT
+	t.Run("aborts the transaction", func(t *testing.T) {
+		wanted := "[init begin produce 0:1 offsets [topicA[0]@2] commit abort"
+		if got := fmt.Sprintf("%v", calls); !strings.HasPrefix(got, wanted) {
+			t.Errorf("wanted %s..., got %s", wanted, got)
+		}
+	})
+
+	t.Run("applies the failure policy", func(t *testing.T) {
+		// the second failure of the message at offset 1 exhausts the attempts
+		// allowed by the policy and the message is skipped
+		wanted := "[1 2 1]"
+		if got := fmt.Sprintf("%v", attempts); got != wanted {
+			t.Errorf("wanted %s, got %s", wanted, got)
+		}
+	})
+
+	t.Run("rewinds the partition to a retried message", func(t *testing.T) {
+		wanted := "[topicA[0]@1 topicA[0]@2]"
+		if got := fmt.Sprintf("%v", sought); got != wanted {
+			t.Errorf("wanted %s, got %s", wanted, got)
+		}
+	})
+
+	t.Run("continues consuming", func(t *testing.T) {
+		if IsFatalError(err) {
+			t.Errorf("unexpected error: %v", err)
+		}
+		if handled != 3 {
+			t.Errorf("wanted %d messages handled, got %d", 3, handled)
+		}
+	})
+}
+
+func TestThatAnExactlyOnceConsumerStopsWhenTheProducerIsFenced(t *testing.T) {
+	// MOCK
+	ch := mock.ConsumerHooks()
+	ch.Messages([]interface{}{
+		partitionMessage("topicA", 0, 1),
+		partitionMessage("topicA", 0, 2),
+	})
+
+	calls := []string{}
+	ph := exactlyOnceHooks(&calls)
+	ph.Funcs().CommitTransaction = func(*kafka.Producer, context.Context) error {
+		calls = append(calls, "commit")
+		return mock.FencedError()
+	}
+
+	// ARRANGE
+	cfg := NewConfig().WithHooks(ch).
+		WithProducerHooks(ph).
+		WithTransactionalId("txn").
+		WithTransactionalHandler("topicA", forward)
+
+	c, _ := NewConsumer(cfg)
+
+	// ACT
+	err := c.Run(context.Background())
+
+	// ASSERT
+	if !IsFatalError(err) {
+		t.Errorf("wanted a fatal error, got %v", err)
+	}
+	wanted := "[init begin produce 0:1 offsets [topicA[0]@2] commit]"
+	if got := fmt.Sprintf("%v", calls); got != wanted {
+		t.Errorf("wanted %s, got %s", wanted, got)
+	}
+}
+
+func TestThatAnExactlyOnceConsumerSendsOffsetsWithTheCurrentGroupMetadata(t *testing.T) {
+	// MOCK
+	metadata := &kafka.ConsumerGroupMetadata{}
+	ch := mock.ConsumerHooks()
+	ch.Funcs().GetConsumerGroupMetadata = func(*kafka.Consumer) (*kafka.ConsumerGroupMetadata, error) {
+		return metadata, nil
+	}
+	ch.Messages([]interface{}{
+		assigned("topicA", 0),
+		partitionMessage("topicA", 0, 1),
+		kafka.RevokedPartitions{Partitions: []kafka.TopicPartition{
+			partition{topic: "topicA", id: 0}.topicPartition(kafka.OffsetInvalid),
+		}},
+		assigned("topicA", 1),
+		partitionMessage("topicA", 1, 1),
+	})
+
+	sent := []*kafka.ConsumerGroupMetadata{}
+	ph := mock.ProducerHooks()
+	ph.Funcs().SendOffsetsToTransaction = func(_ *kafka.Producer, _ context.Context, _ []kafka.TopicPartition, md *kafka.ConsumerGroupMetadata) error {
+		sent = append(sent, md)
+		return nil
+	}
+
+	// ARRANGE
+	generations := []*kafka.ConsumerGroupMetadata{}
+	cfg := NewConfig().WithHooks(ch).
+		WithProducerHooks(ph).
+		WithTransactionalId("txn").
+		WithOnPartitionsAssigned(func(context.Context, []kafka.TopicPartition) error {
+			// Each rebalance is a new generation of the group
+			metadata = &kafka.ConsumerGroupMetadata{}
+			generations = append(generations, metadata)
+			return nil
+		}).
+		WithTransactionalHandler("topicA", forward)
+
+	c, _ := NewConsumer(cfg)
+
+	// ACT
+	c.Run(context.Background())
+
+	// ASSERT
+	if len(sent) != 2 || len(generations) != 2 {
+		t.Fatalf("wanted offsets sent for %d generations, got %d sent for %d", 2, len(sent), len(generations))
+	}
+	for i := range sent {
+		if sent[i] != generations[i] {
+			t.Errorf("offsets sent %d: wanted metadata of generation %d", i+1, i+1)
+		}
+	}
+}
+
+func TestThatAnExactlyOnceConsumerIsConfiguredForTransactions(t *testing.T) {
+	// MOCK
+	var consumerCfg *kafka.ConfigMap
+	ch := mock.ConsumerHooks()
+	ch.Funcs().Create = func(cfg *kafka.ConfigMap) (*kafka.Consumer, error) {
+		consumerCfg = cfg
+		return &kafka.Consumer{}, nil
+	}
+
+	producerCfgs := []*kafka.ConfigMap{}
+	ph := mock.ProducerHooks()
+	ph.Funcs().Create = func(cfg *kafka.ConfigMap) (*kafka.Producer, error) {
+		producerCfgs = append(producerCfgs, cfg)
+		return &kafka.Producer{}, nil
+	}
+
+	// ARRANGE
+	cfg := NewConfig().WithHooks(ch).
+		WithProducerHooks(ph).
+		WithTransactionalId("txn").
+		WithDeadLetterTopic("topicA.dlq").
+		WithTransactionalHandler("topicA", forward)
+
+	// ACT
+	_, err := NewConsumer(cfg)
+
+	// ASSERT
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	t.Run("consumer", func(t *testing.T) {
+		if id, _ := consumerCfg.Get("transactional.id", nil); id != nil {
+			t.Errorf("wanted no transactional id, got %v", id)
+		}
+		if ac, _ := consumerCfg.Get("enable.auto.commit", nil); ac != false {
+			t.Errorf("wanted auto-commit %v, got %v", false, ac)
+		}
+	})
+
+	t.Run("producers", func(t *testing.T) {
+		if len(producerCfgs) != 2 {
+			t.Fatalf("wanted %d producers, got %d", 2, len(producerCfgs))
+		}
+		wanted := []interface{}{"txn", nil} // transactional producer, dead-letter producer
+		for i, cfg := range producerCfgs {
+			if id, _ := cfg.Get("transactional.id", nil); id != wanted[i] {
+				t.Errorf("producer %d: wanted transactional id %v, got %v", i+1, wanted[i], id)
+			}
+		}
+	})
+}
+
+func TestThatAnExactlyOnceConsumerCannotBeConcurrent(t *testing.T) {
+	// ARRANGE
+	cfg := NewConfig().WithHooks(mock.ConsumerHooks()).
+		WithProducerHooks(mock.ProducerHooks()).
+		WithTransactionalId("txn").
+		WithConcurrency(2).
+		WithTransactionalHandler("topicA", forward)
+
+	defer func() {
+		// ASSERT
+		if r := recover(); r == nil {
+			t.Error("did not panic")
+		}
+	}()
+
+	// ACT
+	NewConsumer(cfg)
+}
+
+func TestThatATransactionalHandlerFailsIfTheConsumerIsNotTransactional(t *testing.T) {
+	// MOCK
+	ch := mock.ConsumerHooks()
+	ch.Messages([]interface{}{
+		partitionMessage("topicA", 0, 1),
+	})
+
+	// ARRANGE
+	called := false
+	cfg := NewConfig().WithHooks(ch).
+		WithFailurePolicy(StopOnFailure()).
+		WithTransactionalHandler("topicA", func(context.Context, *kafka.Message, *Transaction) error {
+			called = true
+			return nil
+		})
+
+	c, _ := NewConsumer(cfg)
+
+	// ACT
+	err := c.Run(context.Background())
+
+	// ASSERT
+	if !errors.Is(err, ErrNotTransactional) {
+		t.Errorf("wanted %v, got %v", ErrNotTransactional, err)
+	}
+	if called {
+		t.Error("handler was called")
+	}
+}
@@ -0,0 +1,137 @@
+package kafka
+
+import (
+	"context"
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+)
+
+// FailureAction identifies the action to be taken by a Consumer when a
+// MessageHandler returns an error.
+type FailureAction int
+
+const (
+	// SkipMessage logs the failure and moves on to the next message.  The
+	// offset of the failed message is committed.
+	SkipMessage FailureAction = iota
+
+	// RetryMessage calls the handler again with the same message.
+	RetryMessage
+
+	// StopConsumer stops the Consumer.  The offset of the failed message is
+	// not committed and Run() returns an ErrHandlerFailed.
+	StopConsumer
+
+	// DeadLetterMessage sends the failed message to the dead-letter configured
+	// on the Consumer and moves on to the next message.
+	DeadLetterMessage
+
+	// RetryMessageLater forwards the failed message to the next retry topic
+	// configured for the handler and moves on to the next message.  If there
+	// are no further retry topics, the message is sent to the dead-letter.
+	RetryMessageLater
+)
+
+// Failure describes a message for which a MessageHandler returned an error.
+//
+// For a BatchMessageHandler, Batch holds all messages in the failed batch and
+// Message is the first message in the batch.
+type Failure struct {
+	Message  *kafka.Message
+	Batch    []*kafka.Message
+	Err      error
+	Attempts int // the number of times the handler has been called for the message (including on any retry topics)
+}
+
+// messages returns a Failure for each message involved in a failure.  For a
+// batch failure this is a Failure for each message in the batch, otherwise
+// the Failure itself.
+func (f *Failure) messages() []*Failure {
+	if f.Batch == nil {
+		return []*Failure{f}
+	}
+
+	fa := make([]*Failure, len(f.Batch))
+	for i, msg := range f.Batch {
+		fa[i] = &Failure{Message: msg, Err: f.Err, Attempts: f.Attempts}
+	}
+	return fa
+}
+
+// FailurePolicy determines the action to be taken for a failed message.
+//
+// A policy may block (e.g. to wait before a retry) but should return promptly
+// if the context is cancelled.  The context is cancelled when the context
+// passed to Consumer.Run is cancelled, even while handlers are allowed to
+// complete (see WithDrainTimeout).
+type FailurePolicy func(context.Context, *Failure) FailureAction
+
+// DeadLetterFunc is called by a Consumer to send a message to a dead-letter
+// when the FailurePolicy for the message returns DeadLetterMessage.
+type DeadLetterFunc func(context.Context, *Failure) error
+
+// maxRetryBackoff is the upper limit on the (exponentially increasing) delay
+// between attempts imposed by RetryFailedMessages().
+const maxRetryBackoff = time.Minute
+
+// SkipFailedMessages returns a FailurePolicy which skips any failed message.
+//
+// This is the default policy if no other is configured.
+func SkipFailedMessages() FailurePolicy {
+	return func(context.Context, *Failure) FailureAction { return SkipMessage }
+}
+
+// StopOnFailure returns a FailurePolicy which stops the Consumer when a
+// message fails.
+func StopOnFailure() FailurePolicy {
+	return func(context.Context, *Failure) FailureAction { return StopConsumer }
+}
+
+// DeadLetterFailedMessages returns a FailurePolicy which sends any failed
+// message to the dead-letter configured on the Consumer.
+func DeadLetterFailedMessages() FailurePolicy {
+	return func(context.Context, *Failure) FailureAction { return DeadLetterMessage }
+}
+
+// RetryFailedMessages returns a FailurePolicy which retries a failed message
+// up to a maximum number of attempts (including the first).  Successive
+// attempts are delayed by the specified backoff, doubling with each attempt
+// (up to a maximum of 1 minute).
+//
+// Once the attempts have been exhausted the failure is passed to the 'then'
+// policy.  If 'then' is nil the consumer is stopped.
+//
+// A message which failed because its handler timed out (see Timeout) is
+// retried while the abandoned handler may still be running.
+//
+// If the context is cancelled while waiting to retry (a Consumer cancels the
+// context passed to a FailurePolicy when the context passed to Run is
+// cancelled), the consumer is stopped (leaving the offset of the failed
+// message uncommitted).
+func RetryFailedMessages(attempts int, backoff time.Duration, then FailurePolicy) FailurePolicy {
+	if then == nil {
+		then = StopOnFailure()
+	}
+
+	return func(ctx context.Context, f *Failure) FailureAction {
+		if f.Attempts >= attempts {
+			return then(ctx, f)
+		}
+
+		delay := backoff << (f.Attempts - 1)
+		if delay > maxRetryBackoff || delay < backoff {
+			delay = maxRetryBackoff
+		}
+
+		timer := time.NewTimer(delay)
+		defer timer.Stop()
+
+		select {
+		case <-timer.C:
+			return RetryMessage
+		case <-ctx.Done():
+			return StopConsumer
+		}
+	}
+}
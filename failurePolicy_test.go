@@ -0,0 +1,56 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestThatRetryFailedMessagesRetriesUntilAttemptsAreExhausted(t *testing.T) {
+	// ARRANGE
+	policy := RetryFailedMessages(3, time.Millisecond, SkipFailedMessages())
+	f := &Failure{Err: errors.New("failed")}
+
+	// ACT & ASSERT
+	for attempt, wanted := range []FailureAction{RetryMessage, RetryMessage, SkipMessage} {
+		f.Attempts = attempt + 1
+		got := policy(context.Background(), f)
+		if wanted != got {
+			t.Errorf("attempt %d: wanted %v, got %v", f.Attempts, wanted, got)
+		}
+	}
+}
+
+func TestThatRetryFailedMessagesStopsWhenAttemptsAreExhaustedWithNoThenPolicy(t *testing.T) {
+	// ARRANGE
+	policy := RetryFailedMessages(1, time.Millisecond, nil)
+	f := &Failure{Err: errors.New("failed"), Attempts: 1}
+
+	// ACT
+	got := policy(context.Background(), f)
+
+	// ASSERT
+	wanted := StopConsumer
+	if wanted != got {
+		t.Errorf("wanted %v, got %v", wanted, got)
+	}
+}
+
+func TestThatRetryFailedMessagesStopsIfTheContextIsCancelledDuringBackoff(t *testing.T) {
+	// ARRANGE
+	policy := RetryFailedMessages(2, time.Hour, SkipFailedMessages())
+	f := &Failure{Err: errors.New("failed"), Attempts: 1}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	// ACT
+	got := policy(ctx, f)
+
+	// ASSERT
+	wanted := StopConsumer
+	if wanted != got {
+		t.Errorf("wanted %v, got %v", wanted, got)
+	}
+}
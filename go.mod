@@ -2,4 +2,20 @@ module github.com/deltics/go-kafka
 
 go 1.18
 
-require github.com/confluentinc/confluent-kafka-go v1.9.2
+require (
+	github.com/confluentinc/confluent-kafka-go v1.9.2
+	github.com/heetch/avro v0.3.1
+	google.golang.org/protobuf v1.28.1
+)
+
+require (
+	github.com/actgardner/gogen-avro/v10 v10.2.1 // indirect
+	github.com/golang/protobuf v1.5.2 // indirect
+	github.com/google/uuid v1.3.0 // indirect
+	github.com/iancoleman/orderedmap v0.0.0-20190318233801-ac98e3ecb4b0 // indirect
+	github.com/invopop/jsonschema v0.4.0 // indirect
+	github.com/jhump/protoreflect v1.12.0 // indirect
+	github.com/santhosh-tekuri/jsonschema/v5 v5.0.0 // indirect
+	github.com/stretchr/testify v1.7.5 // indirect
+	google.golang.org/genproto v0.0.0-20220503193339-ba3ae3f07e29 // indirect
+)
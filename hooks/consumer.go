@@ -7,11 +7,21 @@ import (
 )
 
 type ConsumerHooks interface {
+	Assign(*kafka.Consumer, []kafka.TopicPartition) error
 	Create(*kafka.ConfigMap) (*kafka.Consumer, error)
 	Close(*kafka.Consumer)
 	CommitOffset(*kafka.Consumer, []kafka.TopicPartition) ([]kafka.TopicPartition, error)
+	CommitOffsetAsync(*kafka.Consumer, []kafka.TopicPartition, func([]kafka.TopicPartition, error))
+	GetConsumerGroupMetadata(*kafka.Consumer) (*kafka.ConsumerGroupMetadata, error)
+	GetRebalanceProtocol(*kafka.Consumer) string
+	IncrementalAssign(*kafka.Consumer, []kafka.TopicPartition) error
+	OffsetsForTimes(*kafka.Consumer, []kafka.TopicPartition, int) ([]kafka.TopicPartition, error)
+	Pause(*kafka.Consumer, []kafka.TopicPartition) error
 	ReadMessage(*kafka.Consumer, time.Duration) (*kafka.Message, error)
+	Resume(*kafka.Consumer, []kafka.TopicPartition) error
+	Seek(*kafka.Consumer, kafka.TopicPartition, int) error
 	Subscribe(c *kafka.Consumer, ta []string, rcb kafka.RebalanceCb) error
+	Unassign(*kafka.Consumer) error
 }
 
 type consumer struct{}
@@ -20,6 +30,10 @@ func HookConsumer() ConsumerHooks {
 	return &consumer{}
 }
 
+func (*consumer) Assign(c *kafka.Consumer, tpa []kafka.TopicPartition) error {
+	return c.Assign(tpa)
+}
+
 func (*consumer) Close(c *kafka.Consumer) {
 	c.Close()
 }
@@ -28,14 +42,57 @@ func (*consumer) CommitOffset(c *kafka.Consumer, tpa []kafka.TopicPartition) ([]
 	return c.CommitOffsets(tpa)
 }
 
+// CommitOffsetAsync commits offsets without waiting for the commit to
+// complete.  The specified func is called (on a separate goroutine) with the
+// result of the commit once it has completed.  Commits are not ordered with
+// respect to one another; a caller requiring that offsets are committed in
+// order should not make another commit until the func has been called.
+func (*consumer) CommitOffsetAsync(c *kafka.Consumer, tpa []kafka.TopicPartition, fn func([]kafka.TopicPartition, error)) {
+	go func() {
+		fn(c.CommitOffsets(tpa))
+	}()
+}
+
 func (*consumer) Create(cfg *kafka.ConfigMap) (*kafka.Consumer, error) {
 	return kafka.NewConsumer(cfg)
 }
 
+func (*consumer) GetConsumerGroupMetadata(c *kafka.Consumer) (*kafka.ConsumerGroupMetadata, error) {
+	return c.GetConsumerGroupMetadata()
+}
+
+func (*consumer) GetRebalanceProtocol(c *kafka.Consumer) string {
+	return c.GetRebalanceProtocol()
+}
+
+func (*consumer) IncrementalAssign(c *kafka.Consumer, tpa []kafka.TopicPartition) error {
+	return c.IncrementalAssign(tpa)
+}
+
+func (*consumer) OffsetsForTimes(c *kafka.Consumer, times []kafka.TopicPartition, timeoutMs int) ([]kafka.TopicPartition, error) {
+	return c.OffsetsForTimes(times, timeoutMs)
+}
+
+func (*consumer) Pause(c *kafka.Consumer, tpa []kafka.TopicPartition) error {
+	return c.Pause(tpa)
+}
+
+func (*consumer) Resume(c *kafka.Consumer, tpa []kafka.TopicPartition) error {
+	return c.Resume(tpa)
+}
+
+func (*consumer) Seek(c *kafka.Consumer, tp kafka.TopicPartition, timeoutMs int) error {
+	return c.Seek(tp, timeoutMs)
+}
+
 func (*consumer) Subscribe(c *kafka.Consumer, ta []string, rcb kafka.RebalanceCb) error {
 	return c.SubscribeTopics(ta, rcb)
 }
 
+func (*consumer) Unassign(c *kafka.Consumer) error {
+	return c.Unassign()
+}
+
 func (*consumer) ReadMessage(c *kafka.Consumer, t time.Duration) (*kafka.Message, error) {
 	return c.ReadMessage(t)
 }
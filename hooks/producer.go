@@ -1,13 +1,22 @@
 package hooks
 
-import "github.com/confluentinc/confluent-kafka-go/kafka"
+import (
+	"context"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+)
 
 type ProducerHooks interface {
+	AbortTransaction(*kafka.Producer, context.Context) error
+	BeginTransaction(*kafka.Producer) error
 	Close(*kafka.Producer)
+	CommitTransaction(*kafka.Producer, context.Context) error
 	Create(*kafka.ConfigMap) (*kafka.Producer, error)
 	GetEventChannel(*kafka.Producer) chan kafka.Event
 	Flush(*kafka.Producer, int) int
+	InitTransactions(*kafka.Producer, context.Context) error
 	Produce(*kafka.Producer, *kafka.Message, chan kafka.Event) error
+	SendOffsetsToTransaction(*kafka.Producer, context.Context, []kafka.TopicPartition, *kafka.ConsumerGroupMetadata) error
 }
 
 type producer struct{}
@@ -16,10 +25,22 @@ func HookProducer() ProducerHooks {
 	return &producer{}
 }
 
+func (*producer) AbortTransaction(producer *kafka.Producer, ctx context.Context) error {
+	return producer.AbortTransaction(ctx)
+}
+
+func (*producer) BeginTransaction(producer *kafka.Producer) error {
+	return producer.BeginTransaction()
+}
+
 func (*producer) Close(producer *kafka.Producer) {
 	producer.Close()
 }
 
+func (*producer) CommitTransaction(producer *kafka.Producer, ctx context.Context) error {
+	return producer.CommitTransaction(ctx)
+}
+
 func (*producer) Create(cfg *kafka.ConfigMap) (*kafka.Producer, error) {
 	bss, _ := cfg.Get("bootstrap.servers", "")
 	if bss == "test://noclient" {
@@ -36,6 +57,14 @@ func (*producer) Flush(producer *kafka.Producer, timeoutMs int) int {
 	return producer.Flush(timeoutMs)
 }
 
+func (*producer) InitTransactions(producer *kafka.Producer, ctx context.Context) error {
+	return producer.InitTransactions(ctx)
+}
+
 func (*producer) Produce(producer *kafka.Producer, msg *kafka.Message, ch chan kafka.Event) error {
 	return producer.Produce(msg, ch)
 }
+
+func (*producer) SendOffsetsToTransaction(producer *kafka.Producer, ctx context.Context, offsets []kafka.TopicPartition, metadata *kafka.ConsumerGroupMetadata) error {
+	return producer.SendOffsetsToTransaction(ctx, offsets, metadata)
+}
@@ -0,0 +1,72 @@
+package kafka
+
+import (
+	"reflect"
+
+	"github.com/confluentinc/confluent-kafka-go/schemaregistry/serde/jsonschema"
+)
+
+// jsonRecordName returns the name of the Go type T (dereferencing a pointer),
+// identifying the record described by the JSON schema reflected from T.
+func jsonRecordName[T any]() string {
+	t := reflect.TypeOf((*T)(nil)).Elem()
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t.Name()
+}
+
+// JSONSchemaSerializer returns a Serializer which encodes values as JSON using
+// the JSON schema serializer of the schemaregistry package (from
+// confluent-kafka-go) with the schema registry configured on the Config (see
+// WithSchemaRegistry), in the Confluent wire format.  Values are not validated
+// against the schema.
+//
+// The schema is reflected from T and registered under the subject determined
+// by the SubjectNameStrategy of the Config (the record name being the name of
+// the Go type) or, if the Config is configured to use the latest schema (see
+// WithUseLatestSchema), the latest schema registered under the subject is
+// used.
+//
+// JSONSchemaSerializer panics if no schema registry is configured.
+func JSONSchemaSerializer[T any](cfg *config, opts ...SchemaOption) Serializer[T] {
+	s := newSchemaSerde(cfg, opts)
+	ser, err := jsonschema.NewSerializer(s.registry, s.serdeType(), &jsonschema.SerializerConfig{SerializerConfig: s.serializerConfig()})
+	if err != nil {
+		panic(err)
+	}
+	ser.SubjectNameStrategy = s.subjectNameStrategy(jsonRecordName[T]())
+
+	return func(topic string, v T) ([]byte, error) {
+		return ser.Serialize(topic, v)
+	}
+}
+
+// JSONSchemaDeserializer returns a Deserializer which decodes JSON values in
+// the Confluent wire format using the JSON schema deserializer of the
+// schemaregistry package (from confluent-kafka-go) with the schema registry
+// configured on the Config (see WithSchemaRegistry).  Values are not validated
+// against the schema with which they were encoded.
+//
+// The schema is retrieved from the subject determined by the
+// SubjectNameStrategy of the Config, so the options must match those of the
+// Serializer (e.g. ForKeys).
+//
+// JSONSchemaDeserializer panics if no schema registry is configured.
+func JSONSchemaDeserializer[T any](cfg *config, opts ...SchemaOption) Deserializer[T] {
+	s := newSchemaSerde(cfg, opts)
+	des, err := jsonschema.NewDeserializer(s.registry, s.serdeType(), jsonschema.NewDeserializerConfig())
+	if err != nil {
+		panic(err)
+	}
+	des.SubjectNameStrategy = s.subjectNameStrategy(jsonRecordName[T]())
+
+	return func(topic string, b []byte) (T, error) {
+		var v T
+		if _, _, err := wireDecode(b); err != nil {
+			return v, err
+		}
+		err := des.DeserializeInto(topic, b, &v)
+		return v, err
+	}
+}
@@ -0,0 +1,45 @@
+package kafka
+
+import (
+	"testing"
+
+	"github.com/deltics/go-kafka/mock"
+)
+
+type jsonOrder struct {
+	Id string `json:"id"`
+}
+
+func TestThatJSONSchemaSerializedValuesAreDeserialized(t *testing.T) {
+	// ARRANGE
+	sr := mock.SchemaRegistry()
+	cfg := NewConfig().WithSchemaRegistry(sr).WithSubjectNameStrategy(RecordNameStrategy())
+
+	// ACT
+	b, err := JSONSchemaSerializer[jsonOrder](cfg)("orders", jsonOrder{Id: "a"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	got, err := JSONSchemaDeserializer[jsonOrder](cfg)("orders", b)
+
+	// ASSERT
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if got.Id != "a" {
+		t.Errorf("wanted order %q, got %q", "a", got.Id)
+	}
+	if subjects, _ := sr.GetAllSubjects(); len(subjects) != 1 || subjects[0] != "jsonOrder" {
+		t.Errorf("wanted subject %q, got %v", "jsonOrder", subjects)
+	}
+}
+
+func TestThatJSONSchemaDeserializerReturnsErrorForInvalidWireFormat(t *testing.T) {
+	// ACT
+	_, err := JSONSchemaDeserializer[jsonOrder](NewConfig().WithSchemaRegistry(mock.SchemaRegistry()))("orders", []byte(`{"id":"a"}`))
+
+	// ASSERT
+	if err != ErrInvalidWireFormat {
+		t.Errorf("wanted %v, got %v", ErrInvalidWireFormat, err)
+	}
+}
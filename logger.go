@@ -0,0 +1,16 @@
+package kafka
+
+import "log"
+
+// Logger is the interface through which a Consumer logs information that is
+// not otherwise returned to the application, such as skipped messages.
+//
+// A *log.Logger satisfies this interface.
+type Logger interface {
+	Printf(format string, v ...interface{})
+}
+
+// defaultLogger returns the logger to be used if none is configured.
+func defaultLogger() Logger {
+	return log.Default()
+}
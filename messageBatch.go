@@ -0,0 +1,96 @@
+package kafka
+
+import (
+	"context"
+	"hash/fnv"
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+)
+
+// messageBatch is a batch of messages accumulated for a BatchMessageHandler.
+type messageBatch struct {
+	route    route
+	msgs     []*kafka.Message
+	deadline time.Time // the time by which the batch must be handled, however many messages it holds
+}
+
+// batch adds a message to the batch for its topic, starting a new batch if
+// required.  If the batch is then full it is handled.
+func (c *Consumer) batch(hctx context.Context, r route, msg *kafka.Message) error {
+	topic := *msg.TopicPartition.Topic
+
+	b, ok := c.batches[topic]
+	if !ok {
+		b = &messageBatch{
+			route:    r,
+			msgs:     make([]*kafka.Message, 0, r.handler.batchSize),
+			deadline: time.Now().Add(r.handler.batchWait),
+		}
+		c.batches[topic] = b
+	}
+	b.msgs = append(b.msgs, msg)
+
+	if len(b.msgs) < r.handler.batchSize {
+		return nil
+	}
+	return c.flush(hctx, topic)
+}
+
+// flushExpired handles any batches that have reached their deadline at the
+// specified time.
+func (c *Consumer) flushExpired(hctx context.Context, now time.Time) error {
+	for topic, b := range c.batches {
+		if now.Before(b.deadline) {
+			continue
+		}
+		if err := c.flush(hctx, topic); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// flush dispatches the batch for a topic to the handler.
+func (c *Consumer) flush(hctx context.Context, topic string) error {
+	b := c.batches[topic]
+	delete(c.batches, topic)
+
+	for _, msg := range b.msgs {
+		c.offsets.dispatched(msg.TopicPartition)
+		c.throttle(msg.TopicPartition)
+	}
+
+	// Batches for a topic are dispatched to the same worker so that they are
+	// handled in order
+	if c.workers != nil {
+		return c.workers.dispatch(job{route: b.route, msgs: b.msgs}, topicShard(topic), c.completed)
+	}
+
+	if err := c.completed(result{msgs: b.msgs, err: c.handle(hctx, b.route, b.msgs...)}); err != nil {
+		return err
+	}
+	return c.commit()
+}
+
+// readTimeout returns the time to wait for a message, being the poll timeout
+// or the time remaining until the earliest batch deadline, if sooner.
+func (c *Consumer) readTimeout(now time.Time) time.Duration {
+	timeout := pollTimeout
+	for _, b := range c.batches {
+		if wait := b.deadline.Sub(now); wait < timeout {
+			timeout = wait
+		}
+	}
+	if timeout < time.Millisecond {
+		timeout = time.Millisecond
+	}
+	return timeout
+}
+
+// topicShard returns the shard for a batch of messages on a topic.
+func topicShard(topic string) uint32 {
+	h := fnv.New32a()
+	h.Write([]byte(topic))
+	return h.Sum32()
+}
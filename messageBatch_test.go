@@ -0,0 +1,169 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+
+	"github.com/deltics/go-kafka/mock"
+)
+
+// batchMessages returns n messages on a topic with consecutive offsets.
+func batchMessages(topic string, n int) []interface{} {
+	msgs := make([]interface{}, n)
+	for i := range msgs {
+		msg := StringMessage(topic, fmt.Sprintf("%d", i))
+		msg.TopicPartition.Offset = kafka.Offset(i)
+		msgs[i] = msg
+	}
+	return msgs
+}
+
+func TestThatABatchIsHandledWhenItReachesTheMaximumSize(t *testing.T) {
+	// MOCK
+	committed := []kafka.Offset{}
+
+	p := mock.ConsumerHooks()
+	p.Funcs().CommitOffset = func(c *kafka.Consumer, tpa []kafka.TopicPartition) ([]kafka.TopicPartition, error) {
+		for _, tp := range tpa {
+			committed = append(committed, tp.Offset)
+		}
+		return tpa, nil
+	}
+	p.Messages(batchMessages("topicA", 5))
+
+	// ARRANGE
+	batches := [][]string{}
+	cfg := NewConfig().WithHooks(p).
+		WithAutoCommit(false).
+		WithBatchMessageHandler("topicA", func(ctx context.Context, msgs []*kafka.Message) error {
+			batch := []string{}
+			for _, msg := range msgs {
+				batch = append(batch, string(msg.Value))
+			}
+			batches = append(batches, batch)
+			return nil
+		}, 2, time.Hour)
+
+	c, _ := NewConsumer(cfg)
+
+	// ACT
+	c.Run(context.Background())
+
+	// ASSERT
+	wanted := "[[0 1] [2 3]]"
+	got := fmt.Sprintf("%v", batches)
+	if wanted != got {
+		t.Errorf("wanted batches %v, got %v", wanted, got)
+	}
+
+	wanted = "[2 4]"
+	got = fmt.Sprintf("%v", committed)
+	if wanted != got {
+		t.Errorf("wanted commits %v, got %v", wanted, got)
+	}
+}
+
+func TestThatABatchIsHandledWhenTheMaximumWaitElapses(t *testing.T) {
+	// MOCK
+	p := mock.ConsumerHooks()
+	p.Messages(append(batchMessages("topicA", 3), 100*time.Millisecond))
+
+	// ARRANGE
+	sizes := []int{}
+	cfg := NewConfig().WithHooks(p).
+		WithBatchMessageHandler("topicA", func(ctx context.Context, msgs []*kafka.Message) error {
+			sizes = append(sizes, len(msgs))
+			return nil
+		}, 10, 10*time.Millisecond)
+
+	c, _ := NewConsumer(cfg)
+
+	// ACT
+	c.Run(context.Background())
+
+	// ASSERT
+	wanted := "[3]"
+	got := fmt.Sprintf("%v", sizes)
+	if wanted != got {
+		t.Errorf("wanted batch sizes %v, got %v", wanted, got)
+	}
+}
+
+func TestThatEachMessageInAFailedBatchIsSentToTheDeadLetter(t *testing.T) {
+	// MOCK
+	p := mock.ConsumerHooks()
+	p.Messages(batchMessages("topicA", 3))
+
+	// ARRANGE
+	var failure *Failure
+	deadLettered := []string{}
+	cfg := NewConfig().WithHooks(p).
+		WithFailurePolicy(func(ctx context.Context, f *Failure) FailureAction {
+			failure = f
+			return DeadLetterMessage
+		}).
+		WithDeadLetterFunc(func(ctx context.Context, f *Failure) error {
+			deadLettered = append(deadLettered, string(f.Message.Value))
+			return nil
+		}).
+		WithBatchMessageHandler("topicA", func(ctx context.Context, msgs []*kafka.Message) error {
+			return errors.New("failed")
+		}, 3, time.Hour)
+
+	c, _ := NewConsumer(cfg)
+
+	// ACT
+	c.Run(context.Background())
+
+	// ASSERT
+	if failure == nil {
+		t.Fatal("failure policy was not called")
+	}
+	if len(failure.Batch) != 3 {
+		t.Errorf("wanted failure with batch of %d messages, got %d", 3, len(failure.Batch))
+	}
+
+	wanted := "[0 1 2]"
+	got := fmt.Sprintf("%v", deadLettered)
+	if wanted != got {
+		t.Errorf("wanted dead-lettered %v, got %v", wanted, got)
+	}
+}
+
+func TestThatBatchesForATopicAreHandledInOrderWhenConcurrent(t *testing.T) {
+	// MOCK
+	p := mock.ConsumerHooks()
+	p.Messages(append(batchMessages("topicA", 12), 100*time.Millisecond))
+
+	// ARRANGE
+	mu := sync.Mutex{}
+	handled := []string{}
+	cfg := NewConfig().WithHooks(p).
+		WithConcurrency(4).
+		WithBatchMessageHandler("topicA", func(ctx context.Context, msgs []*kafka.Message) error {
+			mu.Lock()
+			defer mu.Unlock()
+			for _, msg := range msgs {
+				handled = append(handled, string(msg.Value))
+			}
+			return nil
+		}, 3, time.Hour)
+
+	c, _ := NewConsumer(cfg)
+
+	// ACT
+	c.Run(context.Background())
+
+	// ASSERT
+	wanted := "[0 1 2 3 4 5 6 7 8 9 10 11]"
+	got := fmt.Sprintf("%v", handled)
+	if wanted != got {
+		t.Errorf("wanted %v, got %v", wanted, got)
+	}
+}
@@ -1,6 +1,110 @@
 package kafka
 
-type messageHandlerMap map[string]MessageHandler
+import (
+	"context"
+	"runtime/debug"
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+)
+
+// messageHandler holds a MessageHandler (or BatchMessageHandler) together with
+// any options configured for the topic it handles.
+type messageHandler struct {
+	fn            MessageHandler
+	batchFn       BatchMessageHandler
+	batchSize     int
+	batchWait     time.Duration
+	failurePolicy FailurePolicy
+	retryTiers    []RetryTier
+	timeout       time.Duration
+
+	deserializationPolicy FailurePolicy
+}
+
+// call calls the handler with the specified messages.  For a MessageHandler
+// there is only ever one message.
+//
+// If the handler has a timeout, the context passed to the handler has a
+// corresponding deadline.  If the handler has not returned when the context is
+// done (the timeout expires or the specified context is cancelled) it is
+// abandoned, left running in its own goroutine, and an ErrHandlerTimeout (or
+// the context error) is returned.  An error returned by the handler after the
+// deadline has passed is also reported as an ErrHandlerTimeout.
+func (h messageHandler) call(ctx context.Context, msgs []*kafka.Message) error {
+	if h.timeout <= 0 {
+		return h.invoke(ctx, msgs)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, h.timeout)
+	defer cancel()
+
+	// Buffered so that an abandoned handler does not block when it returns
+	result := make(chan error, 1)
+	go func() { result <- h.invoke(ctx, msgs) }()
+
+	timedOut := ErrHandlerTimeout{TopicPartition: msgs[0].TopicPartition, Timeout: h.timeout}
+	select {
+	case err := <-result:
+		if err != nil && ctx.Err() == context.DeadlineExceeded {
+			return timedOut
+		}
+		return err
+	case <-ctx.Done():
+		if ctx.Err() == context.DeadlineExceeded {
+			return timedOut
+		}
+		return ctx.Err()
+	}
+}
+
+// invoke calls the handler function with the specified messages.  A panic in
+// the handler is recovered and returned as an ErrHandlerPanic.
+func (h messageHandler) invoke(ctx context.Context, msgs []*kafka.Message) (err error) {
+	defer func() {
+		if v := recover(); v != nil {
+			err = ErrHandlerPanic{TopicPartition: msgs[0].TopicPartition, Value: v, Stack: debug.Stack()}
+		}
+	}()
+
+	if h.batchFn != nil {
+		return h.batchFn(ctx, msgs)
+	}
+	return h.fn(ctx, msgs[0])
+}
+
+// HandlerOption configures options for the handler of a specific topic.
+type HandlerOption func(*messageHandler)
+
+// OnFailure returns a HandlerOption which sets the FailurePolicy for a topic,
+// overriding any FailurePolicy set on the config.
+func OnFailure(policy FailurePolicy) HandlerOption {
+	return func(h *messageHandler) {
+		h.failurePolicy = policy
+	}
+}
+
+// Timeout returns a HandlerOption which limits the time allowed for the
+// handler of a topic to handle a message (or batch).  The context passed to
+// the handler has a corresponding deadline.
+//
+// A handler which has not returned when the timeout expires is abandoned: the
+// consumer treats the message as failed with an ErrHandlerTimeout (subject to
+// the FailurePolicy for the topic) and moves on, leaving the handler running.
+// The consumer does not wait for an abandoned handler to return, so if the
+// FailurePolicy retries the message (or skips it), the retry (or the next
+// message on the partition) may be handled while the abandoned handler is
+// still running; messages are then no longer handled strictly one at a time,
+// in order.  A handler should therefore return promptly when its context is
+// done, and a handler which may be abandoned must be safe to call concurrently
+// with itself.
+func Timeout(d time.Duration) HandlerOption {
+	return func(h *messageHandler) {
+		h.timeout = d
+	}
+}
+
+type messageHandlerMap map[string]messageHandler
 
 func (thm messageHandlerMap) copy() messageHandlerMap {
 	copy := messageHandlerMap{}
@@ -10,10 +114,38 @@ func (thm messageHandlerMap) copy() messageHandlerMap {
 	return copy
 }
 
+// routes returns a map of the topics to be consumed (including retry topics)
+// and the handler route for each.
+func (thm messageHandlerMap) routes() map[string]route {
+	routes := map[string]route{}
+	for k, v := range thm {
+		routes[k] = route{handler: v}
+		for i, tier := range v.retryTiers {
+			routes[tier.Topic] = route{handler: v, tier: i + 1}
+		}
+	}
+	return routes
+}
+
+// hasRetryTiers returns true if retry topics are configured for any handler.
+func (thm messageHandlerMap) hasRetryTiers() bool {
+	for _, v := range thm {
+		if len(v.retryTiers) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// topicIds returns the ids of the topics to be consumed, including any
+// retry topics.
 func (thm messageHandlerMap) topicIds() []string {
 	ids := make([]string, 0, len(thm))
-	for k := range thm {
+	for k, v := range thm {
 		ids = append(ids, k)
+		for _, tier := range v.retryTiers {
+			ids = append(ids, tier.Topic)
+		}
 	}
 	return ids
 }
@@ -0,0 +1,104 @@
+package kafka
+
+import (
+	"context"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+)
+
+// HandlerMiddleware wraps a MessageHandler, returning a MessageHandler which
+// may run code before and after calling the wrapped handler (e.g. for timing,
+// recovery or tracing), modify the context or message passed to it, or not
+// call it at all.
+type HandlerMiddleware func(MessageHandler) MessageHandler
+
+// middlewareChain holds the middleware configured for a Consumer or Producer,
+// in the order added.
+type middlewareChain struct {
+	messages []MessageMiddleware
+	handlers []HandlerMiddleware
+}
+
+func (mc middlewareChain) copy() middlewareChain {
+	return middlewareChain{
+		messages: append([]MessageMiddleware{}, mc.messages...),
+		handlers: append([]HandlerMiddleware{}, mc.handlers...),
+	}
+}
+
+// apply applies the MessageMiddleware to a message, in the order added.  If
+// any middleware returns an error, the error is returned.  If any middleware
+// returns a nil message, nil is returned without applying any further
+// middleware.
+func (mc middlewareChain) apply(msg *kafka.Message) (*kafka.Message, error) {
+	var err error
+	for _, mw := range mc.messages {
+		if msg, err = mw(msg); err != nil || msg == nil {
+			return nil, err
+		}
+	}
+	return msg, nil
+}
+
+// handler returns a MessageHandler which applies the MessageMiddleware to a
+// message before calling the specified handler, wrapped by the
+// HandlerMiddleware (the first added being the outermost).
+func (mc middlewareChain) handler(fn MessageHandler) MessageHandler {
+	h := fn
+	if len(mc.messages) > 0 {
+		h = func(ctx context.Context, msg *kafka.Message) error {
+			msg, err := mc.apply(msg)
+			if err != nil || msg == nil {
+				return err
+			}
+			return fn(ctx, msg)
+		}
+	}
+
+	for i := len(mc.handlers) - 1; i >= 0; i-- {
+		h = mc.handlers[i](h)
+	}
+	return h
+}
+
+// batchHandler returns a BatchMessageHandler which applies the
+// MessageMiddleware to each message in a batch before calling the specified
+// handler.  Messages for which the middleware returns nil are removed from the
+// batch; if no messages remain, the handler is not called.
+func (mc middlewareChain) batchHandler(fn BatchMessageHandler) BatchMessageHandler {
+	if len(mc.messages) == 0 {
+		return fn
+	}
+
+	return func(ctx context.Context, msgs []*kafka.Message) error {
+		batch := make([]*kafka.Message, 0, len(msgs))
+		for _, msg := range msgs {
+			msg, err := mc.apply(msg)
+			if err != nil {
+				return err
+			}
+			if msg != nil {
+				batch = append(batch, msg)
+			}
+		}
+		if len(batch) == 0 {
+			return nil
+		}
+		return fn(ctx, batch)
+	}
+}
+
+// withMiddleware returns a copy of the map with each handler wrapped by the
+// specified middleware.
+func (thm messageHandlerMap) withMiddleware(mc middlewareChain) messageHandlerMap {
+	wrapped := messageHandlerMap{}
+	for k, v := range thm {
+		if v.batchFn != nil {
+			v.batchFn = mc.batchHandler(v.batchFn)
+		} else {
+			v.fn = mc.handler(v.fn)
+		}
+		wrapped[k] = v
+	}
+	return wrapped
+}
@@ -0,0 +1,169 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+
+	"github.com/deltics/go-kafka/mock"
+)
+
+// appendValue returns a MessageMiddleware which appends a string to the
+// value of a message.
+func appendValue(s string) MessageMiddleware {
+	return func(msg *kafka.Message) (*kafka.Message, error) {
+		msg.Value = append(msg.Value, s...)
+		return msg, nil
+	}
+}
+
+func TestThatConsumerMiddlewareIsAppliedInTheOrderAdded(t *testing.T) {
+	// MOCK
+	p := mock.ConsumerHooks()
+	p.Messages([]interface{}{
+		StringMessage("topicA", "message:"),
+	})
+
+	// ARRANGE
+	var handled string
+	cfg := NewConfig().WithHooks(p).
+		WithMiddleware(appendValue("a")).
+		Use(appendValue("b"), appendValue("c")).
+		WithMessageHandler("topicA", func(ctx context.Context, msg *kafka.Message) error {
+			handled = string(msg.Value)
+			return nil
+		})
+
+	c, _ := NewConsumer(cfg)
+
+	// ACT
+	c.Run(context.Background())
+
+	// ASSERT
+	wanted := "message:abc"
+	got := handled
+	if wanted != got {
+		t.Errorf("wanted %q, got %q", wanted, got)
+	}
+}
+
+func TestThatHandlerMiddlewareWrapsTheHandler(t *testing.T) {
+	// MOCK
+	p := mock.ConsumerHooks()
+	p.Messages([]interface{}{
+		StringMessage("topicA", "message"),
+	})
+
+	// ARRANGE
+	calls := []string{}
+	wrap := func(id string) HandlerMiddleware {
+		return func(next MessageHandler) MessageHandler {
+			return func(ctx context.Context, msg *kafka.Message) error {
+				calls = append(calls, id+":before")
+				err := next(ctx, msg)
+				calls = append(calls, id+":after")
+				return err
+			}
+		}
+	}
+	cfg := NewConfig().WithHooks(p).
+		Wrap(wrap("a"), wrap("b")).
+		WithMiddleware(func(msg *kafka.Message) (*kafka.Message, error) {
+			calls = append(calls, "middleware")
+			return msg, nil
+		}).
+		WithMessageHandler("topicA", func(ctx context.Context, msg *kafka.Message) error {
+			calls = append(calls, "handler")
+			return nil
+		})
+
+	c, _ := NewConsumer(cfg)
+
+	// ACT
+	c.Run(context.Background())
+
+	// ASSERT
+	wanted := "[a:before b:before middleware handler b:after a:after]"
+	got := fmt.Sprintf("%v", calls)
+	if wanted != got {
+		t.Errorf("wanted %v, got %v", wanted, got)
+	}
+}
+
+func TestThatTheFailurePolicyIsAppliedWhenConsumerMiddlewareFails(t *testing.T) {
+	// MOCK
+	p := mock.ConsumerHooks()
+	p.Messages([]interface{}{
+		StringMessage("topicA", "message"),
+	})
+
+	// ARRANGE
+	mwErr := errors.New("middleware failed")
+	handled := false
+	cfg := NewConfig().WithHooks(p).
+		WithFailurePolicy(StopOnFailure()).
+		WithMiddleware(func(msg *kafka.Message) (*kafka.Message, error) {
+			return nil, mwErr
+		}).
+		WithMessageHandler("topicA", func(ctx context.Context, msg *kafka.Message) error {
+			handled = true
+			return nil
+		})
+
+	c, _ := NewConsumer(cfg)
+
+	// ACT
+	err := c.Run(context.Background())
+
+	// ASSERT
+	if !errors.Is(err, mwErr) {
+		t.Errorf("wanted %v, got %v", mwErr, err)
+	}
+	if handled {
+		t.Error("message was handled")
+	}
+}
+
+func TestThatAMessageFilteredByConsumerMiddlewareIsCommitted(t *testing.T) {
+	// MOCK
+	committed := kafka.OffsetInvalid
+
+	p := mock.ConsumerHooks()
+	p.Funcs().CommitOffset = func(c *kafka.Consumer, tpa []kafka.TopicPartition) ([]kafka.TopicPartition, error) {
+		committed = tpa[0].Offset
+		return tpa, nil
+	}
+	p.Messages([]interface{}{
+		partitionMessage("topicA", 0, 10),
+	})
+
+	// ARRANGE
+	handled := false
+	cfg := NewConfig().WithHooks(p).
+		WithAutoCommit(false).
+		WithMiddleware(func(msg *kafka.Message) (*kafka.Message, error) {
+			return nil, nil
+		}).
+		WithMessageHandler("topicA", func(ctx context.Context, msg *kafka.Message) error {
+			handled = true
+			return nil
+		})
+
+	c, _ := NewConsumer(cfg)
+
+	// ACT
+	c.Run(context.Background())
+
+	// ASSERT
+	if handled {
+		t.Error("message was handled")
+	}
+	wanted := kafka.Offset(11)
+	got := committed
+	if wanted != got {
+		t.Errorf("wanted offset %v committed, got %v", wanted, got)
+	}
+}
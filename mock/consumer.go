@@ -11,10 +11,20 @@ import (
 )
 
 type consumerFuncs struct {
-	Create       func(cfg *kafka.ConfigMap) (*kafka.Consumer, error)
-	Close        func(c *kafka.Consumer)
-	CommitOffset func(c *kafka.Consumer, tpa []kafka.TopicPartition) ([]kafka.TopicPartition, error)
-	Subscribe    func(c *kafka.Consumer, ta []string, rcb kafka.RebalanceCb) error
+	Assign                   func(c *kafka.Consumer, tpa []kafka.TopicPartition) error
+	Create                   func(cfg *kafka.ConfigMap) (*kafka.Consumer, error)
+	Close                    func(c *kafka.Consumer)
+	CommitOffset             func(c *kafka.Consumer, tpa []kafka.TopicPartition) ([]kafka.TopicPartition, error)
+	CommitOffsetAsync        func(c *kafka.Consumer, tpa []kafka.TopicPartition, fn func([]kafka.TopicPartition, error))
+	GetConsumerGroupMetadata func(c *kafka.Consumer) (*kafka.ConsumerGroupMetadata, error)
+	GetRebalanceProtocol     func(c *kafka.Consumer) string
+	IncrementalAssign        func(c *kafka.Consumer, tpa []kafka.TopicPartition) error
+	OffsetsForTimes          func(c *kafka.Consumer, times []kafka.TopicPartition, timeoutMs int) ([]kafka.TopicPartition, error)
+	Pause                    func(c *kafka.Consumer, tpa []kafka.TopicPartition) error
+	Resume                   func(c *kafka.Consumer, tpa []kafka.TopicPartition) error
+	Seek                     func(c *kafka.Consumer, tp kafka.TopicPartition, timeoutMs int) error
+	Subscribe                func(c *kafka.Consumer, ta []string, rcb kafka.RebalanceCb) error
+	Unassign                 func(c *kafka.Consumer) error
 }
 
 type consumerHooks interface {
@@ -27,24 +37,57 @@ type consumer struct {
 	funcs        consumerFuncs
 	messages     []interface{}
 	messageIndex int
+	rebalanceCb  kafka.RebalanceCb
 }
 
 func ConsumerHooks() consumerHooks {
-	return &consumer{
+	// CommitOffsetAsync commits synchronously (using the CommitOffset func) by
+	// default, so the consumer is referenced by the func
+	var c *consumer
+	c = &consumer{
 		messages: []interface{}{},
 		funcs: consumerFuncs{
+			Assign:       func(c *kafka.Consumer, tpa []kafka.TopicPartition) error { return nil },
 			Create:       func(cfg *kafka.ConfigMap) (*kafka.Consumer, error) { return &kafka.Consumer{}, nil },
 			Close:        func(c *kafka.Consumer) {},
 			CommitOffset: func(c *kafka.Consumer, tpa []kafka.TopicPartition) ([]kafka.TopicPartition, error) { return tpa, nil },
-			Subscribe:    func(c *kafka.Consumer, ta []string, rcb kafka.RebalanceCb) error { return nil },
+			CommitOffsetAsync: func(consumer *kafka.Consumer, tpa []kafka.TopicPartition, fn func([]kafka.TopicPartition, error)) {
+				fn(c.funcs.CommitOffset(consumer, tpa))
+			},
+			GetConsumerGroupMetadata: func(c *kafka.Consumer) (*kafka.ConsumerGroupMetadata, error) {
+				return &kafka.ConsumerGroupMetadata{}, nil
+			},
+			GetRebalanceProtocol: func(c *kafka.Consumer) string { return "EAGER" },
+			IncrementalAssign:    func(c *kafka.Consumer, tpa []kafka.TopicPartition) error { return nil },
+			// OffsetsForTimes returns the times (timestamps in the Offset of each partition) as offsets
+			OffsetsForTimes: func(c *kafka.Consumer, times []kafka.TopicPartition, timeoutMs int) ([]kafka.TopicPartition, error) {
+				return times, nil
+			},
+			Pause:     func(c *kafka.Consumer, tpa []kafka.TopicPartition) error { return nil },
+			Resume:    func(c *kafka.Consumer, tpa []kafka.TopicPartition) error { return nil },
+			Seek:      func(c *kafka.Consumer, tp kafka.TopicPartition, timeoutMs int) error { return nil },
+			Subscribe: func(c *kafka.Consumer, ta []string, rcb kafka.RebalanceCb) error { return nil },
+			Unassign:  func(c *kafka.Consumer) error { return nil },
 		},
 	}
+	return c
 }
 
 func (c *consumer) Funcs() *consumerFuncs {
 	return &c.funcs
 }
 
+// Messages adds items to be returned by ReadMessage.  Each item may be:
+//
+//	*kafka.Message:  returned as a message
+//	error:           returned as an error
+//	time.Duration:   a period during which no message is received; ReadMessage
+//	                 waits for the duration then returns a timeout error
+//
+//	kafka.AssignedPartitions,
+//	kafka.RevokedPartitions: a rebalance; ReadMessage calls the rebalance
+//	                 callback passed to Subscribe (if any) with the event then
+//	                 returns a timeout error
 func (c *consumer) Messages(msgs []interface{}) {
 	c.messages = append(c.messages, msgs...)
 }
@@ -53,6 +96,10 @@ func (c *consumer) Create(cfg *kafka.ConfigMap) (*kafka.Consumer, error) {
 	return c.funcs.Create(cfg)
 }
 
+func (c *consumer) Assign(consumer *kafka.Consumer, partitions []kafka.TopicPartition) error {
+	return c.funcs.Assign(consumer, partitions)
+}
+
 func (c *consumer) Close(consumer *kafka.Consumer) {
 	c.funcs.Close(consumer)
 }
@@ -61,10 +108,47 @@ func (c *consumer) CommitOffset(consumer *kafka.Consumer, partition []kafka.Topi
 	return c.funcs.CommitOffset(consumer, partition)
 }
 
+func (c *consumer) CommitOffsetAsync(consumer *kafka.Consumer, partitions []kafka.TopicPartition, fn func([]kafka.TopicPartition, error)) {
+	c.funcs.CommitOffsetAsync(consumer, partitions, fn)
+}
+
+func (c *consumer) GetConsumerGroupMetadata(consumer *kafka.Consumer) (*kafka.ConsumerGroupMetadata, error) {
+	return c.funcs.GetConsumerGroupMetadata(consumer)
+}
+
+func (c *consumer) GetRebalanceProtocol(consumer *kafka.Consumer) string {
+	return c.funcs.GetRebalanceProtocol(consumer)
+}
+
+func (c *consumer) IncrementalAssign(consumer *kafka.Consumer, partitions []kafka.TopicPartition) error {
+	return c.funcs.IncrementalAssign(consumer, partitions)
+}
+
+func (c *consumer) OffsetsForTimes(consumer *kafka.Consumer, times []kafka.TopicPartition, timeoutMs int) ([]kafka.TopicPartition, error) {
+	return c.funcs.OffsetsForTimes(consumer, times, timeoutMs)
+}
+
+func (c *consumer) Pause(consumer *kafka.Consumer, partitions []kafka.TopicPartition) error {
+	return c.funcs.Pause(consumer, partitions)
+}
+
+func (c *consumer) Resume(consumer *kafka.Consumer, partitions []kafka.TopicPartition) error {
+	return c.funcs.Resume(consumer, partitions)
+}
+
+func (c *consumer) Seek(consumer *kafka.Consumer, partition kafka.TopicPartition, timeoutMs int) error {
+	return c.funcs.Seek(consumer, partition, timeoutMs)
+}
+
 func (c *consumer) Subscribe(consumer *kafka.Consumer, topics []string, rebalanceCallback kafka.RebalanceCb) error {
+	c.rebalanceCb = rebalanceCallback
 	return c.funcs.Subscribe(consumer, topics, rebalanceCallback)
 }
 
+func (c *consumer) Unassign(consumer *kafka.Consumer) error {
+	return c.funcs.Unassign(consumer)
+}
+
 func (c *consumer) ReadMessage(consumer *kafka.Consumer, timeout time.Duration) (*kafka.Message, error) {
 	if c.messageIndex >= len(c.messages) {
 		return nil, errors.New("no more messages")
@@ -78,6 +162,14 @@ func (c *consumer) ReadMessage(consumer *kafka.Consumer, timeout time.Duration)
 		return msg, nil
 	case error:
 		return nil, msg
+	case time.Duration:
+		time.Sleep(msg)
+		return nil, kafka.NewError(kafka.ErrTimedOut, "timed out", false)
+	case kafka.AssignedPartitions, kafka.RevokedPartitions:
+		if c.rebalanceCb != nil {
+			c.rebalanceCb(consumer, msg.(kafka.Event))
+		}
+		return nil, kafka.NewError(kafka.ErrTimedOut, "timed out", false)
 	}
 	return nil, fmt.Errorf("unexpected item of type %T in mock message list", msg)
 }
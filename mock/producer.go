@@ -1,17 +1,25 @@
 package mock
 
 import (
+	"context"
+	"fmt"
+
 	"github.com/confluentinc/confluent-kafka-go/kafka"
 
 	"github.com/deltics/go-kafka/hooks"
 )
 
 type producerFuncs struct {
-	Close        func(*kafka.Producer)
-	Create       func(*kafka.ConfigMap) (*kafka.Producer, error)
-	EventChannel func(*kafka.Producer) chan kafka.Event
-	Flush        func(*kafka.Producer, int) int
-	Produce      func(*kafka.Producer, *kafka.Message, chan kafka.Event) error
+	AbortTransaction         func(*kafka.Producer, context.Context) error
+	BeginTransaction         func(*kafka.Producer) error
+	Close                    func(*kafka.Producer)
+	CommitTransaction        func(*kafka.Producer, context.Context) error
+	Create                   func(*kafka.ConfigMap) (*kafka.Producer, error)
+	EventChannel             func(*kafka.Producer) chan kafka.Event
+	Flush                    func(*kafka.Producer, int) int
+	InitTransactions         func(*kafka.Producer, context.Context) error
+	Produce                  func(*kafka.Producer, *kafka.Message, chan kafka.Event) error
+	SendOffsetsToTransaction func(*kafka.Producer, context.Context, []kafka.TopicPartition, *kafka.ConsumerGroupMetadata) error
 }
 
 type producer struct {
@@ -30,11 +38,18 @@ func ProducerHooks() MockProducerProvider {
 	return &producer{
 		events: Events,
 		funcs: producerFuncs{
-			Close:        func(*kafka.Producer) { close(Events) },
-			Create:       func(*kafka.ConfigMap) (*kafka.Producer, error) { return &kafka.Producer{}, nil },
-			EventChannel: func(*kafka.Producer) chan kafka.Event { return Events },
-			Flush:        func(*kafka.Producer, int) int { return 0 },
-			Produce:      func(*kafka.Producer, *kafka.Message, chan kafka.Event) error { return nil },
+			AbortTransaction:  func(*kafka.Producer, context.Context) error { return nil },
+			BeginTransaction:  func(*kafka.Producer) error { return nil },
+			Close:             func(*kafka.Producer) { close(Events) },
+			CommitTransaction: func(*kafka.Producer, context.Context) error { return nil },
+			Create:            func(*kafka.ConfigMap) (*kafka.Producer, error) { return &kafka.Producer{}, nil },
+			EventChannel:      func(*kafka.Producer) chan kafka.Event { return Events },
+			Flush:             func(*kafka.Producer, int) int { return 0 },
+			InitTransactions:  func(*kafka.Producer, context.Context) error { return nil },
+			Produce:           func(*kafka.Producer, *kafka.Message, chan kafka.Event) error { return nil },
+			SendOffsetsToTransaction: func(*kafka.Producer, context.Context, []kafka.TopicPartition, *kafka.ConsumerGroupMetadata) error {
+				return nil
+			},
 		},
 	}
 }
@@ -43,10 +58,22 @@ func (p *producer) Funcs() *producerFuncs {
 	return &p.funcs
 }
 
+func (p *producer) AbortTransaction(producer *kafka.Producer, ctx context.Context) error {
+	return p.funcs.AbortTransaction(producer, ctx)
+}
+
+func (p *producer) BeginTransaction(producer *kafka.Producer) error {
+	return p.funcs.BeginTransaction(producer)
+}
+
 func (p *producer) Close(producer *kafka.Producer) {
 	p.funcs.Close(producer)
 }
 
+func (p *producer) CommitTransaction(producer *kafka.Producer, ctx context.Context) error {
+	return p.funcs.CommitTransaction(producer, ctx)
+}
+
 func (p *producer) Create(cfg *kafka.ConfigMap) (*kafka.Producer, error) {
 	return p.funcs.Create(cfg)
 }
@@ -59,6 +86,52 @@ func (p *producer) Flush(producer *kafka.Producer, timeoutMs int) int {
 	return p.funcs.Flush(producer, timeoutMs)
 }
 
+func (p *producer) InitTransactions(producer *kafka.Producer, ctx context.Context) error {
+	return p.funcs.InitTransactions(producer, ctx)
+}
+
 func (p *producer) Produce(producer *kafka.Producer, msg *kafka.Message, ch chan kafka.Event) error {
 	return p.funcs.Produce(producer, msg, ch)
 }
+
+func (p *producer) SendOffsetsToTransaction(producer *kafka.Producer, ctx context.Context, offsets []kafka.TopicPartition, metadata *kafka.ConsumerGroupMetadata) error {
+	return p.funcs.SendOffsetsToTransaction(producer, ctx, offsets, metadata)
+}
+
+// TxnError is an error returned by a mocked transactional operation.  It is
+// classified in the same way as a kafka.Error, which cannot be created with
+// all classifications outside of the kafka package.
+type TxnError struct {
+	Code      kafka.ErrorCode
+	Fatal     bool
+	Retriable bool
+	Abortable bool
+}
+
+func (e TxnError) Error() string {
+	return fmt.Sprintf("mock transaction error: %v", e.Code)
+}
+
+func (e TxnError) IsFatal() bool          { return e.Fatal }
+func (e TxnError) IsRetriable() bool      { return e.Retriable }
+func (e TxnError) TxnRequiresAbort() bool { return e.Abortable }
+
+// FencedError returns the fatal error returned by a transactional operation
+// when the producer has been fenced by another producer with the same
+// transactional id.
+func FencedError() error {
+	return TxnError{Code: kafka.ErrFenced, Fatal: true}
+}
+
+// AbortableError returns an error returned by a transactional operation when
+// the transaction has failed and must be aborted (e.g. because a message in
+// the transaction could not be delivered).
+func AbortableError() error {
+	return TxnError{Code: kafka.ErrInvalidTxnState, Abortable: true}
+}
+
+// RetriableError returns an error returned by a transactional operation which
+// may be retried (e.g. a timeout).
+func RetriableError() error {
+	return TxnError{Code: kafka.ErrTimedOut, Retriable: true}
+}
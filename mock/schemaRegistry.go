@@ -0,0 +1,32 @@
+package mock
+
+import (
+	"github.com/confluentinc/confluent-kafka-go/schemaregistry"
+)
+
+// schemaRegistry is the mock schema registry client of the schemaregistry
+// package.
+type schemaRegistry struct {
+	schemaregistry.Client
+}
+
+// SchemaRegistry returns an in-memory schema registry client for use in tests
+// (the mock client of the schemaregistry package).
+func SchemaRegistry() schemaregistry.Client {
+	client, err := schemaregistry.NewClient(schemaregistry.NewConfig("mock://"))
+	if err != nil {
+		panic(err)
+	}
+	return schemaRegistry{client}
+}
+
+// Register registers a schema under a subject.  The mock client returns an id
+// of 0 for a schema already registered under the subject, so the id of such a
+// schema is obtained from the mock client using GetID.
+func (r schemaRegistry) Register(subject string, schema schemaregistry.SchemaInfo, normalize bool) (int, error) {
+	id, err := r.Client.Register(subject, schema, normalize)
+	if err == nil && id == 0 {
+		return r.Client.GetID(subject, schema, normalize)
+	}
+	return id, err
+}
@@ -0,0 +1,138 @@
+package kafka
+
+import "github.com/confluentinc/confluent-kafka-go/kafka"
+
+// offsetTracker tracks the offsets of messages dispatched to handlers to
+// determine the offset that may be committed for each partition.  This is the
+// offset following the highest offset at or below which all dispatched
+// messages have been completed, i.e. the offset of the next message to be
+// consumed from the partition, should the consumer be restarted.
+//
+// An offsetTracker is not safe for concurrent use.
+type offsetTracker struct {
+	partitions map[partition]*partitionOffsets
+}
+
+// partitionOffsets tracks the offsets of messages dispatched for a partition.
+type partitionOffsets struct {
+	pending   []pendingOffset // offsets dispatched and not yet committable, in order
+	commit    kafka.Offset    // the offset that may be committed
+	committed kafka.Offset    // the offset last committed
+}
+
+// pendingOffset is the offset of a dispatched message and whether the message
+// has been completed.
+type pendingOffset struct {
+	offset    kafka.Offset
+	completed bool
+}
+
+func newOffsetTracker() *offsetTracker {
+	return &offsetTracker{partitions: map[partition]*partitionOffsets{}}
+}
+
+// dispatched records the dispatch of a message to a handler.
+func (t *offsetTracker) dispatched(tp kafka.TopicPartition) {
+	p := partitionOf(tp)
+
+	po, ok := t.partitions[p]
+	if !ok {
+		po = &partitionOffsets{commit: kafka.OffsetInvalid, committed: kafka.OffsetInvalid}
+		t.partitions[p] = po
+	}
+	po.pending = append(po.pending, pendingOffset{offset: tp.Offset})
+}
+
+// completed records the completion of a dispatched message, advancing the
+// offset that may be committed for the partition if all messages at lower
+// offsets have also been completed.
+func (t *offsetTracker) completed(tp kafka.TopicPartition) {
+	po, ok := t.partitions[partitionOf(tp)]
+	if !ok {
+		return
+	}
+
+	for i := range po.pending {
+		if po.pending[i].offset == tp.Offset {
+			po.pending[i].completed = true
+			break
+		}
+	}
+
+	n := 0
+	for n < len(po.pending) && po.pending[n].completed {
+		po.commit = po.pending[n].offset + 1
+		n++
+	}
+	po.pending = po.pending[n:]
+}
+
+// inFlight returns the number of dispatched messages that have not been
+// completed.
+func (t *offsetTracker) inFlight() int {
+	n := 0
+	for _, po := range t.partitions {
+		for _, p := range po.pending {
+			if !p.completed {
+				n++
+			}
+		}
+	}
+	return n
+}
+
+// inFlightOn returns the number of dispatched messages on a partition that
+// have not been completed.
+func (t *offsetTracker) inFlightOn(p partition) int {
+	po, ok := t.partitions[p]
+	if !ok {
+		return 0
+	}
+
+	n := 0
+	for _, p := range po.pending {
+		if !p.completed {
+			n++
+		}
+	}
+	return n
+}
+
+// remove stops tracking the offsets for a partition (e.g. when the partition
+// is revoked from the consumer).
+func (t *offsetTracker) remove(p partition) {
+	delete(t.partitions, p)
+}
+
+// uncommitted returns the offsets that may be committed for any partitions
+// which have advanced since they were last committed.
+func (t *offsetTracker) uncommitted() []kafka.TopicPartition {
+	tpa := []kafka.TopicPartition{}
+	for p, po := range t.partitions {
+		if po.commit != po.committed {
+			tpa = append(tpa, p.topicPartition(po.commit))
+		}
+	}
+	return tpa
+}
+
+// all returns the offsets that may be committed for all partitions, whether or
+// not they have advanced since they were last committed.
+func (t *offsetTracker) all() []kafka.TopicPartition {
+	tpa := []kafka.TopicPartition{}
+	for p, po := range t.partitions {
+		if po.commit != kafka.OffsetInvalid {
+			tpa = append(tpa, p.topicPartition(po.commit))
+		}
+	}
+	return tpa
+}
+
+// committed records that the specified offsets have been committed.
+func (t *offsetTracker) committed(tpa []kafka.TopicPartition) {
+	for _, tp := range tpa {
+		if po, ok := t.partitions[partitionOf(tp)]; ok {
+			po.committed = tp.Offset
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package kafka
+
+import (
+	"testing"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+)
+
+func Test_OffsetTracker(t *testing.T) {
+	p := partition{topic: "topic", id: 1}
+
+	t.Run("commits the offset following completed messages", func(t *testing.T) {
+		tracker := newOffsetTracker()
+		tracker.dispatched(p.topicPartition(10))
+		tracker.dispatched(p.topicPartition(11))
+		tracker.completed(p.topicPartition(10))
+		tracker.completed(p.topicPartition(11))
+
+		tpa := tracker.uncommitted()
+		if len(tpa) != 1 {
+			t.Fatalf("wanted %d offset(s), got %d", 1, len(tpa))
+		}
+		wanted := kafka.Offset(12)
+		got := tpa[0].Offset
+		if wanted != got {
+			t.Errorf("wanted %v, got %v", wanted, got)
+		}
+	})
+
+	t.Run("does not commit beyond an incomplete message", func(t *testing.T) {
+		tracker := newOffsetTracker()
+		tracker.dispatched(p.topicPartition(10))
+		tracker.dispatched(p.topicPartition(11))
+		tracker.dispatched(p.topicPartition(12))
+		tracker.completed(p.topicPartition(10))
+		tracker.completed(p.topicPartition(12))
+
+		tpa := tracker.uncommitted()
+		if len(tpa) != 1 {
+			t.Fatalf("wanted %d offset(s), got %d", 1, len(tpa))
+		}
+		wanted := kafka.Offset(11)
+		got := tpa[0].Offset
+		if wanted != got {
+			t.Errorf("wanted %v, got %v", wanted, got)
+		}
+
+		wantedInFlight := 1
+		gotInFlight := tracker.inFlight()
+		if wantedInFlight != gotInFlight {
+			t.Errorf("wanted %d in flight, got %d", wantedInFlight, gotInFlight)
+		}
+	})
+
+	t.Run("commits nothing if the first message is incomplete", func(t *testing.T) {
+		tracker := newOffsetTracker()
+		tracker.dispatched(p.topicPartition(10))
+		tracker.dispatched(p.topicPartition(11))
+		tracker.completed(p.topicPartition(11))
+
+		if tpa := tracker.uncommitted(); len(tpa) != 0 {
+			t.Errorf("wanted no offsets, got %v", tpa)
+		}
+	})
+
+	t.Run("does not return offsets that have been committed", func(t *testing.T) {
+		tracker := newOffsetTracker()
+		tracker.dispatched(p.topicPartition(10))
+		tracker.completed(p.topicPartition(10))
+		tracker.committed(tracker.uncommitted())
+
+		if tpa := tracker.uncommitted(); len(tpa) != 0 {
+			t.Errorf("wanted no offsets, got %v", tpa)
+		}
+	})
+}
@@ -0,0 +1,22 @@
+package kafka
+
+import "github.com/confluentinc/confluent-kafka-go/kafka"
+
+// partition identifies a topic partition.  Unlike a kafka.TopicPartition it
+// is comparable by value, so may be used as a map key.
+type partition struct {
+	topic string
+	id    int32
+}
+
+// partitionOf returns the partition identified by a kafka.TopicPartition.
+func partitionOf(tp kafka.TopicPartition) partition {
+	return partition{topic: *tp.Topic, id: tp.Partition}
+}
+
+// topicPartition returns a kafka.TopicPartition for the partition with a
+// specified offset.
+func (p partition) topicPartition(offset kafka.Offset) kafka.TopicPartition {
+	topic := p.topic
+	return kafka.TopicPartition{Topic: &topic, Partition: p.id, Offset: offset}
+}
@@ -0,0 +1,204 @@
+package kafka
+
+import (
+	"sync"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+)
+
+// topicPauses records the topics paused by Consumer.Pause() and resumed by
+// Consumer.Resume().  A topicPauses is safe for concurrent use.
+type topicPauses struct {
+	sync.Mutex
+	all     bool            // all topics are paused (other than those resumed)
+	topics  map[string]bool // topics paused
+	resumed map[string]bool // topics resumed while all topics are paused
+}
+
+func newTopicPauses() *topicPauses {
+	return &topicPauses{topics: map[string]bool{}, resumed: map[string]bool{}}
+}
+
+// pause pauses the specified topics, or all topics if none are specified.
+func (tp *topicPauses) pause(topics []string) {
+	tp.Lock()
+	defer tp.Unlock()
+
+	if len(topics) == 0 {
+		tp.all = true
+		tp.topics = map[string]bool{}
+		tp.resumed = map[string]bool{}
+		return
+	}
+	for _, t := range topics {
+		tp.topics[t] = true
+		delete(tp.resumed, t)
+	}
+}
+
+// resume resumes the specified topics, or all topics if none are specified.
+func (tp *topicPauses) resume(topics []string) {
+	tp.Lock()
+	defer tp.Unlock()
+
+	if len(topics) == 0 {
+		tp.all = false
+		tp.topics = map[string]bool{}
+		tp.resumed = map[string]bool{}
+		return
+	}
+	for _, t := range topics {
+		delete(tp.topics, t)
+		if tp.all {
+			tp.resumed[t] = true
+		}
+	}
+}
+
+// paused returns true if the specified topic is paused.
+func (tp *topicPauses) paused(topic string) bool {
+	tp.Lock()
+	defer tp.Unlock()
+
+	return tp.topics[topic] || (tp.all && !tp.resumed[topic])
+}
+
+// Pause pauses consumption of the specified topics (or all topics, if none
+// are specified) until they are resumed.  Messages already dispatched to
+// handlers are not affected.
+//
+// The consumer continues to poll while topics are paused, so remains a member
+// of the consumer group however long the topics are paused.  Partitions of a
+// paused topic assigned to the consumer while the topic is paused are also
+// paused.
+//
+// Pause may be called from any goroutine, including from a handler; the pause
+// takes effect before the consumer next reads a message.
+func (c *Consumer) Pause(topics ...string) {
+	c.pauses.pause(topics)
+}
+
+// Resume resumes consumption of the specified topics (or all topics, if none
+// are specified) paused by Pause.  Partitions paused for any other reason
+// (e.g. backpressure, or to defer a message on a retry topic) remain paused
+// until that reason no longer applies.
+//
+// Resume may be called from any goroutine, including from a handler.
+func (c *Consumer) Resume(topics ...string) {
+	c.pauses.resume(topics)
+}
+
+// holding returns true if consumption of a partition should be paused,
+// whether paused by Pause, to defer a message on a retry topic or because the
+// partition has reached the backpressure threshold.
+func (c *Consumer) holding(p partition) bool {
+	_, deferred := c.paused[p]
+	return deferred || c.throttled[p] || c.pauses.paused(p.topic)
+}
+
+// hold pauses a partition (if not already paused).
+func (c *Consumer) hold(p partition) error {
+	if c.held[p] {
+		return nil
+	}
+	if err := c.hooks.Pause(c.consumer, []kafka.TopicPartition{p.topicPartition(kafka.OffsetInvalid)}); err != nil {
+		return err
+	}
+	c.held[p] = true
+	return nil
+}
+
+// release resumes a paused partition, unless it should remain paused for
+// some other reason.
+func (c *Consumer) release(p partition) error {
+	if c.holding(p) {
+		return nil
+	}
+	if err := c.hooks.Resume(c.consumer, []kafka.TopicPartition{p.topicPartition(kafka.OffsetInvalid)}); err != nil {
+		return err
+	}
+	delete(c.held, p)
+	delete(c.rewound, p)
+	return nil
+}
+
+// unhold resumes any of the specified partitions that are paused on the kafka
+// consumer, whatever the reason for the pause.  The state of a partition is
+// discarded when it is revoked, which would otherwise leave the partition
+// paused with no record of the pause.
+func (c *Consumer) unhold(partitions map[partition]bool) error {
+	tpa := []kafka.TopicPartition{}
+	for p := range partitions {
+		if c.held[p] {
+			tpa = append(tpa, p.topicPartition(kafka.OffsetInvalid))
+		}
+	}
+	if len(tpa) == 0 {
+		return nil
+	}
+	if err := c.hooks.Resume(c.consumer, tpa); err != nil {
+		return err
+	}
+	for p := range partitions {
+		delete(c.held, p)
+		delete(c.rewound, p)
+	}
+	return nil
+}
+
+// reconcilePauses pauses any partitions which should be paused and resumes any
+// paused partitions which no longer need to be.
+func (c *Consumer) reconcilePauses() error {
+	for p := range c.partitions {
+		if c.held[p] || !c.holding(p) {
+			continue
+		}
+		if err := c.hold(p); err != nil {
+			return err
+		}
+	}
+	for p := range c.held {
+		if err := c.release(p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rewind resets the position of a paused partition to a message read from
+// the partition (having been fetched before the partition was paused), so
+// that the message is read again when the partition is resumed.  Only the
+// first such message is significant; the position is reset only once.
+func (c *Consumer) rewind(msg *kafka.Message) error {
+	p := partitionOf(msg.TopicPartition)
+	if c.rewound[p] {
+		return nil
+	}
+
+	tp := msg.TopicPartition
+	tp.Error = nil
+	if err := c.hooks.Seek(c.consumer, tp, 0); err != nil {
+		return err
+	}
+	c.rewound[p] = true
+	return nil
+}
+
+// throttle applies backpressure to the partition of a dispatched message,
+// pausing the partition if the number of messages in-flight on the partition
+// has reached the threshold.
+func (c *Consumer) throttle(tp kafka.TopicPartition) {
+	if c.config.backpressure > 0 && c.offsets.inFlightOn(partitionOf(tp)) >= c.config.backpressure {
+		c.throttled[partitionOf(tp)] = true
+	}
+}
+
+// unthrottle removes backpressure from the partition of a completed message
+// once the number of messages in-flight on the partition has fallen to half
+// the threshold.
+func (c *Consumer) unthrottle(tp kafka.TopicPartition) {
+	p := partitionOf(tp)
+	if c.throttled[p] && c.offsets.inFlightOn(p) <= c.config.backpressure/2 {
+		delete(c.throttled, p)
+	}
+}
@@ -0,0 +1,165 @@
+package kafka
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+
+	"github.com/deltics/go-kafka/hooks"
+	"github.com/deltics/go-kafka/mock"
+)
+
+// messageHooks is the interface of mock consumer hooks used by tests which
+// need only to add messages to the hooks.
+type messageHooks interface {
+	hooks.ConsumerHooks
+	Messages([]interface{})
+}
+
+// pauseHooks returns mock consumer hooks which record calls to Pause, Resume
+// and Seek on the specified channel.
+func pauseHooks(calls chan string) messageHooks {
+	p := mock.ConsumerHooks()
+	p.Funcs().Pause = func(c *kafka.Consumer, tpa []kafka.TopicPartition) error {
+		calls <- fmt.Sprintf("pause %v", tpa)
+		return nil
+	}
+	p.Funcs().Resume = func(c *kafka.Consumer, tpa []kafka.TopicPartition) error {
+		calls <- fmt.Sprintf("resume %v", tpa)
+		return nil
+	}
+	p.Funcs().Seek = func(c *kafka.Consumer, tp kafka.TopicPartition, timeoutMs int) error {
+		calls <- fmt.Sprintf("seek %v", tp)
+		return nil
+	}
+	return p
+}
+
+// received returns the items received on a channel until it is closed.
+func received(ch chan string) []string {
+	items := []string{}
+	for item := range ch {
+		items = append(items, item)
+	}
+	return items
+}
+
+func TestThatAHandlerCanPauseATopic(t *testing.T) {
+	// MOCK
+	calls := make(chan string, 10)
+	p := pauseHooks(calls)
+	p.Messages([]interface{}{
+		partitionMessage("topicA", 0, 0),
+		partitionMessage("topicA", 0, 1),
+		partitionMessage("topicA", 0, 2),
+	})
+
+	// ARRANGE
+	var c *Consumer
+	handled := 0
+	cfg := NewConfig().WithHooks(p).
+		WithMessageHandler("topicA", func(ctx context.Context, msg *kafka.Message) error {
+			handled++
+			c.Pause("topicA")
+			return nil
+		})
+
+	c, _ = NewConsumer(cfg)
+
+	// ACT
+	c.Run(context.Background())
+
+	// ASSERT
+	close(calls)
+	wanted := "[pause [topicA[0]@unset] seek topicA[0]@1]"
+	got := fmt.Sprintf("%v", received(calls))
+	if wanted != got {
+		t.Errorf("wanted %v, got %v", wanted, got)
+	}
+	if handled != 1 {
+		t.Errorf("wanted %d message handled, got %d", 1, handled)
+	}
+}
+
+func TestThatAPausedTopicIsResumed(t *testing.T) {
+	// ARRANGE
+	calls := make(chan string, 10)
+	cfg := NewConfig().WithHooks(pauseHooks(calls)).
+		WithMessageHandler("topicA", func(ctx context.Context, msg *kafka.Message) error { return nil })
+
+	c, _ := NewConsumer(cfg)
+	c.partitions[partition{topic: "topicA", id: 0}] = true
+
+	c.Pause()
+	c.reconcilePauses()
+
+	// ACT
+	c.Resume("topicA")
+	c.reconcilePauses()
+
+	// ASSERT
+	close(calls)
+	wanted := "[pause [topicA[0]@unset] resume [topicA[0]@unset]]"
+	got := fmt.Sprintf("%v", received(calls))
+	if wanted != got {
+		t.Errorf("wanted %v, got %v", wanted, got)
+	}
+}
+
+func TestThatAPartitionIsPausedAndResumedByBackpressure(t *testing.T) {
+	// MOCK
+	calls := make(chan string, 10)
+	p := pauseHooks(calls)
+	p.Messages([]interface{}{
+		partitionMessage("topicA", 0, 0),
+		partitionMessage("topicA", 0, 1),
+		partitionMessage("topicA", 0, 2),
+		100 * time.Millisecond,
+		100 * time.Millisecond,
+	})
+
+	// ARRANGE
+	release := make(chan struct{})
+	cfg := NewConfig().WithHooks(p).
+		WithConcurrency(2).
+		WithBackpressure(2).
+		WithMessageHandler("topicA", func(ctx context.Context, msg *kafka.Message) error {
+			<-release
+			return nil
+		})
+
+	c, _ := NewConsumer(cfg)
+
+	// ACT
+	done := make(chan struct{})
+	go func() {
+		c.Run(context.Background())
+		close(done)
+	}()
+
+	// ASSERT
+	wanted := "pause [topicA[0]@unset]"
+	got := <-calls
+	if wanted != got {
+		t.Errorf("wanted %q, got %q", wanted, got)
+	}
+
+	wanted = "seek topicA[0]@2"
+	got = <-calls
+	if wanted != got {
+		t.Errorf("wanted %q, got %q", wanted, got)
+	}
+
+	close(release)
+
+	wanted = "resume [topicA[0]@unset]"
+	got = <-calls
+	if wanted != got {
+		t.Errorf("wanted %q, got %q", wanted, got)
+	}
+
+	<-done
+}
@@ -0,0 +1,153 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+)
+
+// queueFullFlushMs is the time for which the producer is flushed when a message
+// in a batch cannot be produced because the queue of the producer is full,
+// before producing the message again.
+const queueFullFlushMs = 100
+
+// DeliveryResult is the result of delivering a message produced by
+// ProduceBatch.  Message is the delivered message if it was delivered,
+// otherwise the message as passed to ProduceBatch.  Dropped is true if a
+// middleware returned a nil message for the message, which was therefore not
+// produced.
+type DeliveryResult struct {
+	Message *kafka.Message
+	Err     error
+	Dropped bool
+}
+
+// BatchResult is the result of delivering the messages produced by
+// ProduceBatch, with a DeliveryResult for each message in the order in which
+// the messages were passed to ProduceBatch.
+type BatchResult struct {
+	Results []DeliveryResult
+}
+
+// DeliveredMessages returns the messages that were delivered.
+func (r BatchResult) DeliveredMessages() []*kafka.Message {
+	msgs := []*kafka.Message{}
+	for _, dr := range r.Results {
+		if dr.Err == nil && !dr.Dropped {
+			msgs = append(msgs, dr.Message)
+		}
+	}
+	return msgs
+}
+
+// DroppedMessages returns the messages that were not produced because a
+// middleware returned a nil message for them.
+func (r BatchResult) DroppedMessages() []*kafka.Message {
+	msgs := []*kafka.Message{}
+	for _, dr := range r.Results {
+		if dr.Dropped {
+			msgs = append(msgs, dr.Message)
+		}
+	}
+	return msgs
+}
+
+// FailedMessages returns the messages that were not delivered (or whose
+// delivery was not confirmed before the context was done).
+func (r BatchResult) FailedMessages() []*kafka.Message {
+	msgs := []*kafka.Message{}
+	for _, dr := range r.Results {
+		if dr.Err != nil {
+			msgs = append(msgs, dr.Message)
+		}
+	}
+	return msgs
+}
+
+// Err returns nil if all of the messages were delivered, otherwise an
+// ErrBatchFailed.
+func (r BatchResult) Err() error {
+	err := ErrBatchFailed{Messages: len(r.Results)}
+	for _, dr := range r.Results {
+		if dr.Err != nil {
+			if err.Failed == 0 {
+				err.Err = dr.Err
+			}
+			err.Failed++
+		}
+	}
+	if err.Failed == 0 {
+		return nil
+	}
+	return err
+}
+
+// ProduceBatch produces messages and waits for the delivery event for every
+// message, returning the result of delivering each message.  The messages are
+// produced without waiting for each to be delivered (see ProduceAsync).
+//
+// If a message cannot be produced because the queue of the producer is full
+// (kafka.ErrQueueFull), the producer is flushed to make room in the queue and
+// the message produced again, until the context is done.
+//
+// If the context is done before the delivery of every message is known, the
+// remaining messages fail with the context error; they may yet be delivered.
+// If a middleware returns a nil message for a message, that message is not
+// produced and is reported as dropped (see DroppedMessages); it is neither
+// delivered nor failed.
+func (p *producer) ProduceBatch(ctx context.Context, msgs []*kafka.Message) BatchResult {
+	return produceBatch(ctx, msgs, p.prepare, p.produceAsync, p.Flush)
+}
+
+// produceBatch produces messages and waits for the result of delivering each
+// message.  Each message is prepared for producing (applying any middleware)
+// once, using the specified func, then produced using the specified func.  A
+// message which cannot be produced because the queue is full is produced again
+// after flushing the producer using the specified func, until the context is
+// done.
+func produceBatch(ctx context.Context, msgs []*kafka.Message, prepare func(*kafka.Message) (*kafka.Message, error), produce func(*kafka.Message) *DeliveryFuture, flush func(int) int) BatchResult {
+	r := BatchResult{Results: make([]DeliveryResult, len(msgs))}
+
+	futures := make([]*DeliveryFuture, len(msgs))
+	for i, msg := range msgs {
+		m, err := prepare(msg)
+		switch {
+		case err != nil:
+			futures[i] = deliveredFuture(nil, err)
+			continue
+		case m == nil:
+			r.Results[i].Dropped = true
+			futures[i] = deliveredFuture(nil, nil)
+			continue
+		}
+
+		futures[i] = produce(m)
+		for isQueueFull(futures[i]) && ctx.Err() == nil {
+			flush(queueFullFlushMs)
+			futures[i] = produce(m)
+		}
+	}
+
+	for i, f := range futures {
+		delivered, err := f.Wait(ctx)
+		if delivered == nil {
+			delivered = msgs[i]
+		}
+		r.Results[i].Message, r.Results[i].Err = delivered, err
+	}
+	return r
+}
+
+// isQueueFull returns true if a DeliveryFuture is done with kafka.ErrQueueFull
+// (the message could not be produced because the queue of the producer is
+// full).
+func isQueueFull(f *DeliveryFuture) bool {
+	select {
+	case <-f.Done():
+		var kerr kafka.Error
+		return errors.As(f.err, &kerr) && kerr.Code() == kafka.ErrQueueFull
+	default:
+		return false
+	}
+}
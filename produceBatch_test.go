@@ -0,0 +1,281 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+
+	"github.com/deltics/go-kafka/mock"
+)
+
+// batchHooks returns producer hooks which deliver each message produced,
+// except for messages with the value "fail", which fail to be delivered, and
+// "error", which cannot be produced.
+func batchHooks() mock.MockProducerProvider {
+	hk := mock.ProducerHooks()
+	hk.Funcs().Produce = func(p *kafka.Producer, m *kafka.Message, c chan kafka.Event) error {
+		switch string(m.Value) {
+		case "error":
+			return errors.New("produce error")
+		case "fail":
+			m.TopicPartition.Error = errors.New("delivery error")
+		}
+		go func() { c <- m }()
+		return nil
+	}
+	return hk
+}
+
+// values returns the values of messages.
+func values(msgs []*kafka.Message) string {
+	v := []string{}
+	for _, msg := range msgs {
+		v = append(v, string(msg.Value))
+	}
+	return fmt.Sprintf("%v", v)
+}
+
+func TestThatProduceBatchReturnsTheResultOfDeliveringEachMessage(t *testing.T) {
+	// ARRANGE
+	p, _ := NewProducer(NewConfig().WithHooks(batchHooks()))
+
+	msgs := []*kafka.Message{
+		StringMessage("topic", "a"),
+		StringMessage("topic", "fail"),
+		StringMessage("topic", "b"),
+		StringMessage("topic", "error"),
+	}
+
+	// ACT
+	result := p.ProduceBatch(context.Background(), msgs)
+
+	// ASSERT
+	t.Run("results", func(t *testing.T) {
+		got := []string{}
+		for _, r := range result.Results {
+			got = append(got, fmt.Sprintf("%s:%v", r.Message.Value, r.Err))
+		}
+		wanted := "[a:<nil> fail:delivery error b:<nil> error:produce error]"
+		if fmt.Sprintf("%v", got) != wanted {
+			t.Errorf("wanted %s, got %v", wanted, got)
+		}
+	})
+
+	t.Run("delivered messages", func(t *testing.T) {
+		wanted := "[a b]"
+		if got := values(result.DeliveredMessages()); got != wanted {
+			t.Errorf("wanted %s, got %s", wanted, got)
+		}
+	})
+
+	t.Run("failed messages", func(t *testing.T) {
+		wanted := "[fail error]"
+		if got := values(result.FailedMessages()); got != wanted {
+			t.Errorf("wanted %s, got %s", wanted, got)
+		}
+	})
+
+	t.Run("error", func(t *testing.T) {
+		err := ErrBatchFailed{}
+		if !errors.As(result.Err(), &err) {
+			t.Fatalf("wanted %T, got %v", err, result.Err())
+		}
+		if err.Messages != 4 || err.Failed != 2 {
+			t.Errorf("wanted %d of %d failed, got %d of %d", 2, 4, err.Failed, err.Messages)
+		}
+		if err.Err.Error() != "delivery error" {
+			t.Errorf("wanted %q, got %q", "delivery error", err.Err)
+		}
+	})
+}
+
+func TestThatProduceBatchReturnsNoErrorIfAllMessagesAreDelivered(t *testing.T) {
+	// ARRANGE
+	p, _ := NewProducer(NewConfig().WithHooks(batchHooks()))
+
+	// ACT
+	result := p.ProduceBatch(context.Background(), []*kafka.Message{
+		StringMessage("topic", "a"),
+		StringMessage("topic", "b"),
+	})
+
+	// ASSERT
+	if err := result.Err(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if n := len(result.FailedMessages()); n != 0 {
+		t.Errorf("wanted %d failed messages, got %d", 0, n)
+	}
+}
+
+func TestThatProduceBatchFailsUndeliveredMessagesWhenTheContextIsDone(t *testing.T) {
+	// ARRANGE
+	hk := mock.ProducerHooks()
+	hk.Funcs().Produce = func(p *kafka.Producer, m *kafka.Message, c chan kafka.Event) error {
+		// Only message "a" is delivered
+		if string(m.Value) == "a" {
+			c <- m
+		}
+		return nil
+	}
+	p, _ := NewProducer(NewConfig().WithHooks(hk))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	// ACT
+	result := p.ProduceBatch(ctx, []*kafka.Message{
+		StringMessage("topic", "a"),
+		StringMessage("topic", "b"),
+	})
+
+	// ASSERT
+	wanted := "[b]"
+	if got := values(result.FailedMessages()); got != wanted {
+		t.Errorf("wanted %s, got %s", wanted, got)
+	}
+	if !errors.Is(result.Err(), context.DeadlineExceeded) {
+		t.Errorf("wanted %v, got %v", context.DeadlineExceeded, result.Err())
+	}
+}
+
+func TestThatProduceBatchFlushesAndProducesAgainWhenTheQueueIsFull(t *testing.T) {
+	// ARRANGE
+	calls := []string{}
+	full := 2 // the queue is full for the first two attempts to produce message "b"
+	hk := batchHooks()
+	produce := hk.Funcs().Produce
+	hk.Funcs().Produce = func(p *kafka.Producer, m *kafka.Message, c chan kafka.Event) error {
+		calls = append(calls, "produce "+string(m.Value))
+		if string(m.Value) == "b" && full > 0 {
+			full--
+			return kafka.NewError(kafka.ErrQueueFull, "queue full", false)
+		}
+		return produce(p, m, c)
+	}
+	hk.Funcs().Flush = func(*kafka.Producer, int) int {
+		calls = append(calls, "flush")
+		return 0
+	}
+	p, _ := NewProducer(NewConfig().WithHooks(hk))
+
+	// ACT
+	result := p.ProduceBatch(context.Background(), []*kafka.Message{
+		StringMessage("topic", "a"),
+		StringMessage("topic", "b"),
+		StringMessage("topic", "c"),
+	})
+
+	// ASSERT
+	if err := result.Err(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	wanted := "[produce a produce b flush produce b flush produce b produce c]"
+	if got := fmt.Sprintf("%v", calls); got != wanted {
+		t.Errorf("wanted %s, got %s", wanted, got)
+	}
+}
+
+func TestThatProduceBatchFailsAMessageWhenTheQueueIsFullUntilTheContextIsDone(t *testing.T) {
+	// ARRANGE
+	hk := batchHooks()
+	produce := hk.Funcs().Produce
+	hk.Funcs().Produce = func(p *kafka.Producer, m *kafka.Message, c chan kafka.Event) error {
+		if string(m.Value) == "b" {
+			return kafka.NewError(kafka.ErrQueueFull, "queue full", false)
+		}
+		return produce(p, m, c)
+	}
+	p, _ := NewProducer(NewConfig().WithHooks(hk))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	// ACT
+	result := p.ProduceBatch(ctx, []*kafka.Message{
+		StringMessage("topic", "a"),
+		StringMessage("topic", "b"),
+	})
+
+	// ASSERT
+	wanted := "[b]"
+	if got := values(result.FailedMessages()); got != wanted {
+		t.Errorf("wanted %s, got %s", wanted, got)
+	}
+}
+
+func TestThatProduceBatchAppliesMiddlewareOnceWhenTheQueueIsFull(t *testing.T) {
+	// ARRANGE
+	full := 2 // the queue is full for the first two attempts to produce the message
+	hk := batchHooks()
+	produce := hk.Funcs().Produce
+	hk.Funcs().Produce = func(p *kafka.Producer, m *kafka.Message, c chan kafka.Event) error {
+		if full > 0 {
+			full--
+			return kafka.NewError(kafka.ErrQueueFull, "queue full", false)
+		}
+		return produce(p, m, c)
+	}
+	applied := 0
+	p, _ := NewProducer(NewConfig().WithHooks(hk).
+		WithMiddleware(func(msg *kafka.Message) (*kafka.Message, error) {
+			applied++
+			return msg, nil
+		}))
+
+	// ACT
+	result := p.ProduceBatch(context.Background(), []*kafka.Message{
+		StringMessage("topic", "a"),
+	})
+
+	// ASSERT
+	if err := result.Err(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if applied != 1 {
+		t.Errorf("wanted middleware applied %d time(s), got %d", 1, applied)
+	}
+}
+
+func TestThatProduceBatchReportsMessagesDroppedByMiddleware(t *testing.T) {
+	// ARRANGE
+	p, _ := NewProducer(NewConfig().WithHooks(batchHooks()).
+		WithMiddleware(func(msg *kafka.Message) (*kafka.Message, error) {
+			if string(msg.Value) == "drop" {
+				return nil, nil
+			}
+			return msg, nil
+		}))
+
+	// ACT
+	result := p.ProduceBatch(context.Background(), []*kafka.Message{
+		StringMessage("topic", "a"),
+		StringMessage("topic", "drop"),
+		StringMessage("topic", "b"),
+	})
+
+	// ASSERT
+	t.Run("delivered messages", func(t *testing.T) {
+		wanted := "[a b]"
+		if got := values(result.DeliveredMessages()); got != wanted {
+			t.Errorf("wanted %s, got %s", wanted, got)
+		}
+	})
+
+	t.Run("dropped messages", func(t *testing.T) {
+		wanted := "[drop]"
+		if got := values(result.DroppedMessages()); got != wanted {
+			t.Errorf("wanted %s, got %s", wanted, got)
+		}
+	})
+
+	t.Run("error", func(t *testing.T) {
+		if err := result.Err(); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+}
@@ -2,6 +2,7 @@ package kafka
 
 import (
 	"context"
+	"sync"
 
 	"github.com/confluentinc/confluent-kafka-go/kafka"
 
@@ -16,8 +17,10 @@ type producer struct {
 	hooks          hooks.ProducerHooks
 	config         *config
 	producer       *kafka.Producer
-	middleware     MessageMiddleware
+	middleware     middlewareChain
 	DeliveryEvents chan kafka.Event
+	closed         chan struct{} // closed when the producer is closed, completing any pending DeliveryFuture
+	closeOnce      sync.Once
 }
 
 type ProducerEventHandler interface {
@@ -30,10 +33,14 @@ type ProducerEventHandler interface {
 func NewProducer(cfg *config) (*producer, error) {
 	phk, ok := cfg.hooks.(hooks.ProducerHooks)
 	if !ok {
-		if cfg.hooks != nil {
+		switch {
+		case cfg.producerHooks != nil:
+			phk = cfg.producerHooks
+		case cfg.hooks != nil:
 			panic("invalid hooks")
+		default:
+			phk = hooks.HookProducer()
 		}
-		phk = hooks.HookProducer()
 	}
 
 	var kp *kafka.Producer
@@ -42,17 +49,30 @@ func NewProducer(cfg *config) (*producer, error) {
 		return nil, err
 	}
 
+	if cfg.transactionalId() != "" {
+		ctx, cancel := context.WithTimeout(context.Background(), initTransactionsTimeout)
+		defer cancel()
+		if err := phk.InitTransactions(kp, ctx); err != nil {
+			phk.Close(kp)
+			return nil, err
+		}
+	}
+
 	return &producer{
 		hooks:          phk,
 		config:         cfg.copy(),
 		producer:       kp,
-		middleware:     cfg.middleware,
+		middleware:     cfg.middleware.copy(),
 		DeliveryEvents: phk.GetEventChannel(kp),
+		closed:         make(chan struct{}),
 	}, nil
 }
 
+// Close closes the producer.  Any DeliveryFuture (see ProduceAsync) which is
+// not yet done is done with ErrProducerClosed.
 func (p *producer) Close() {
 	p.hooks.Close(p.producer)
+	p.closeOnce.Do(func() { close(p.closed) })
 }
 
 func (p *producer) Flush(timeoutMs int) int {
@@ -111,26 +131,38 @@ func (p *producer) HandleEvents(ctx context.Context, handler ProducerEventHandle
 
 // MustProduce produces a message and waits for a delivery event.  The produced
 // message is returned if successful, otherwise an error is returned.
+//
+// Any middleware configured for the producer is applied to the message before
+// it is produced.  If a middleware returns an error, the message is not
+// produced and the error is returned.  If a middleware returns a nil message,
+// the message is not produced and a nil message and error are returned.
+//
+// MustProduce waits for the delivery event for no longer than any delivery
+// timeout configured for the producer (see MustProduceContext).
 func (p *producer) MustProduce(msg *kafka.Message) (*kafka.Message, error) {
+	return p.MustProduceContext(context.Background(), msg)
+}
 
-	if msg.TopicPartition.Topic == nil || *msg.TopicPartition.Topic == "" {
-		return nil, &ErrNoTopicId{message: "message has no topic id"}
-	}
-
-	dc := make(chan kafka.Event)
-	defer close(dc)
-
-	if err := p.hooks.Produce(p.producer, msg, dc); err != nil {
-		return nil, err
-	}
-
-	event := <-dc
-
-	return CheckEvent(event)
+// MustProduceContext produces a message and waits for a delivery event (see
+// MustProduce), until the context is done or any delivery timeout configured
+// for the producer (see WithDeliveryTimeout) expires.  If the context is done
+// the context error is returned; if the timeout expires an ErrDeliveryTimeout
+// is returned.  In either case the message may yet be delivered.
+func (p *producer) MustProduceContext(ctx context.Context, msg *kafka.Message) (*kafka.Message, error) {
+	return p.ProduceAsync(msg).Wait(ctx)
 }
 
 // Produce produces a message.  Delivery events are received over the producer.EventChannel
+//
+// Any middleware configured for the producer is applied to the message before
+// it is produced.  If a middleware returns an error, the message is not
+// produced and the error is returned.  If a middleware returns a nil message,
+// the message is not produced (and no error returned).
 func (p *producer) Produce(msg *kafka.Message) error {
+	msg, err := p.middleware.apply(msg)
+	if err != nil || msg == nil {
+		return err
+	}
 	return p.hooks.Produce(p.producer, msg, nil)
 }
 
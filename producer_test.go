@@ -1,9 +1,11 @@
 package kafka
 
 import (
+	"context"
 	"errors"
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/confluentinc/confluent-kafka-go/kafka"
 	"github.com/deltics/go-kafka/hooks"
@@ -137,6 +139,26 @@ func TestThatNewProducerPanicsIfConfigHasConsumerHooks(t *testing.T) {
 	}
 }
 
+func TestThatNewProducerUsesProducerHooksIfConfigHasConsumerHooks(t *testing.T) {
+	// ARRANGE
+	wanted := mock.ProducerHooks()
+	cfg := NewConfig().
+		WithHooks(mock.ConsumerHooks()).
+		WithProducerHooks(wanted)
+
+	// ACT
+	p, err := NewProducer(cfg)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// ASSERT
+	got := p.hooks
+	if wanted != got {
+		t.Errorf("wanted %v, got %v", wanted, got)
+	}
+}
+
 func TestThatCloseCallsCloseOnTheProducer(t *testing.T) {
 
 	closeCalled := false
@@ -258,6 +280,72 @@ func TestThatProducerMustProduceReturnsSuccessfullyDeliveredMessage(t *testing.T
 		t.Errorf("wanted %v, got %v", wanted, got)
 	}
 }
+
+func TestThatProducerMustProduceContextReturnsTheContextErrorWhenTheContextIsCancelled(t *testing.T) {
+	// ARRANGE
+	topic := "test"
+	msg := kafka.Message{TopicPartition: kafka.TopicPartition{Topic: &topic}}
+
+	// The mock producer never sends a delivery event
+	p, _ := NewProducer(NewConfig().WithHooks(mock.ProducerHooks()))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	// ACT
+	got, err := p.MustProduceContext(ctx, &msg)
+
+	// ASSERT
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("wanted %v, got %v", context.DeadlineExceeded, err)
+	}
+	if got != nil {
+		t.Errorf("wanted nil message, got %v", got)
+	}
+}
+
+func TestThatProducerMustProduceReturnsADeliveryTimeoutError(t *testing.T) {
+	// ARRANGE
+	topic := "test"
+	msg := kafka.Message{TopicPartition: kafka.TopicPartition{Topic: &topic}}
+
+	var dc chan kafka.Event
+	hk := mock.ProducerHooks()
+	hk.Funcs().Produce = func(p *kafka.Producer, m *kafka.Message, c chan kafka.Event) error {
+		dc = c
+		return nil
+	}
+	p, _ := NewProducer(NewConfig().WithHooks(hk).WithDeliveryTimeout(10 * time.Millisecond))
+
+	// ACT
+	_, err := p.MustProduce(&msg)
+
+	// ASSERT
+	t.Run("returns ErrDeliveryTimeout", func(t *testing.T) {
+		dte := ErrDeliveryTimeout{}
+		if !errors.As(err, &dte) {
+			t.Fatalf("wanted %T, got %v", dte, err)
+		}
+		if dte.Timeout != 10*time.Millisecond {
+			t.Errorf("wanted timeout %v, got %v", 10*time.Millisecond, dte.Timeout)
+		}
+	})
+
+	t.Run("which is an ErrTimeOut", func(t *testing.T) {
+		if !errors.Is(err, ErrTimeOut{}) {
+			t.Errorf("wanted %v, got %v", ErrTimeOut{}, err)
+		}
+	})
+
+	t.Run("accepts a late delivery event", func(t *testing.T) {
+		select {
+		case dc <- &msg:
+		case <-time.After(time.Second):
+			t.Error("delivery event was not accepted")
+		}
+	})
+}
+
 func TestThatProducerMustProduceReturnsMessageDeliveryErrorWithFailedMessage(t *testing.T) {
 	// ARRANGE
 	topic := "test"
@@ -431,3 +519,74 @@ func TestThatMustProduceCreatesAndClosesTemporaryProducerWithSpecifiedConfig(t *
 		t.Error("producer was not closed")
 	}
 }
+
+func TestThatProducerMiddlewareIsAppliedToProducedMessages(t *testing.T) {
+	// ARRANGE
+	topic := "test"
+	msg := &kafka.Message{
+		TopicPartition: kafka.TopicPartition{Topic: &topic},
+		Value:          []byte("test value"),
+	}
+
+	var produced *kafka.Message
+	hk := mock.ProducerHooks()
+	hk.Funcs().Produce = func(p *kafka.Producer, m *kafka.Message, c chan kafka.Event) error {
+		produced = m
+		return nil
+	}
+
+	cfg := NewConfig().WithHooks(hk).
+		Use(func(m *kafka.Message) (*kafka.Message, error) {
+			m.Headers = append(m.Headers, kafka.Header{Key: "stamp", Value: []byte("stamped")})
+			return m, nil
+		})
+	p, _ := NewProducer(cfg)
+
+	// ACT
+	err := p.Produce(msg)
+
+	// ASSERT
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if produced == nil || len(produced.Headers) != 1 || string(produced.Headers[0].Value) != "stamped" {
+		t.Errorf("wanted message with stamp header, got %v", produced)
+	}
+}
+
+func TestThatProducerMustProduceReturnsMiddlewareErrorWithoutProducing(t *testing.T) {
+	// ARRANGE
+	topic := "test"
+	msg := &kafka.Message{
+		TopicPartition: kafka.TopicPartition{Topic: &topic},
+		Value:          []byte("test value"),
+	}
+
+	produced := false
+	hk := mock.ProducerHooks()
+	hk.Funcs().Produce = func(p *kafka.Producer, m *kafka.Message, c chan kafka.Event) error {
+		produced = true
+		return nil
+	}
+
+	mwErr := errors.New("invalid message")
+	cfg := NewConfig().WithHooks(hk).
+		WithMiddleware(func(m *kafka.Message) (*kafka.Message, error) {
+			return nil, mwErr
+		})
+	p, _ := NewProducer(cfg)
+
+	// ACT
+	got, err := p.MustProduce(msg)
+
+	// ASSERT
+	if err != mwErr {
+		t.Errorf("wanted error %v, got %v", mwErr, err)
+	}
+	if got != nil {
+		t.Errorf("wanted nil message, got %v", got)
+	}
+	if produced {
+		t.Error("message was produced")
+	}
+}
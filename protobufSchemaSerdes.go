@@ -0,0 +1,77 @@
+package kafka
+
+import (
+	"github.com/confluentinc/confluent-kafka-go/schemaregistry/serde/protobuf"
+	"google.golang.org/protobuf/proto"
+)
+
+// ProtobufSchemaSerializer returns a Serializer which encodes protobuf messages
+// of type T (a pointer to a generated message type, e.g. *pb.Order) using the
+// protobuf serializer of the schemaregistry package (from confluent-kafka-go)
+// with the schema registry configured on the Config (see WithSchemaRegistry),
+// in the Confluent wire format.
+//
+// The schema is the .proto file defining the message type, registered under
+// the subject determined by the SubjectNameStrategy of the Config (the record
+// name being the full name of the message type).  Imported files are
+// registered as schema references.  If the Config is configured to use the
+// latest schema (see WithUseLatestSchema), the latest schema registered under
+// the subject is used.
+//
+// An ErrInvalidProtobufType error is returned if T is not a pointer to a
+// generated message type.  ProtobufSchemaSerializer panics if no schema
+// registry is configured.
+func ProtobufSchemaSerializer[T proto.Message](cfg *config, opts ...SchemaOption) (Serializer[T], error) {
+	mt, err := protobufMessageType[T]()
+	if err != nil {
+		return nil, err
+	}
+
+	s := newSchemaSerde(cfg, opts)
+	ser, err := protobuf.NewSerializer(s.registry, s.serdeType(), &protobuf.SerializerConfig{SerializerConfig: s.serializerConfig()})
+	if err != nil {
+		panic(err)
+	}
+	ser.SubjectNameStrategy = s.subjectNameStrategy(string(mt.Descriptor().FullName()))
+
+	return func(topic string, v T) ([]byte, error) {
+		return ser.Serialize(topic, v)
+	}, nil
+}
+
+// ProtobufSchemaDeserializer returns a Deserializer which decodes protobuf
+// messages of type T (a pointer to a generated message type, e.g. *pb.Order)
+// in the Confluent wire format, using the protobuf deserializer of the
+// schemaregistry package (from confluent-kafka-go) with the schema registry
+// configured on the Config (see WithSchemaRegistry).  The message is decoded
+// as T, whatever the schema with which it was encoded, so the schema is not
+// retrieved from the schema registry.
+//
+// An ErrInvalidProtobufType error is returned if T is not a pointer to a
+// generated message type.  ProtobufSchemaDeserializer panics if no schema
+// registry is configured.
+func ProtobufSchemaDeserializer[T proto.Message](cfg *config, opts ...SchemaOption) (Deserializer[T], error) {
+	mt, err := protobufMessageType[T]()
+	if err != nil {
+		return nil, err
+	}
+
+	s := newSchemaSerde(cfg, opts)
+	des, err := protobuf.NewDeserializer(s.registry, s.serdeType(), protobuf.NewDeserializerConfig())
+	if err != nil {
+		panic(err)
+	}
+	des.SubjectNameStrategy = s.subjectNameStrategy(string(mt.Descriptor().FullName()))
+
+	return func(topic string, b []byte) (T, error) {
+		var zero T
+		if _, _, err := wireDecode(b); err != nil {
+			return zero, err
+		}
+		v := mt.New().Interface().(T)
+		if err := des.DeserializeInto(topic, b, v); err != nil {
+			return zero, err
+		}
+		return v, nil
+	}, nil
+}
@@ -0,0 +1,75 @@
+package kafka
+
+import (
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+
+	"github.com/deltics/go-kafka/mock"
+)
+
+func TestThatProtobufSchemaSerializedMessagesAreDeserialized(t *testing.T) {
+	// ARRANGE
+	sr := mock.SchemaRegistry()
+	cfg := NewConfig().WithSchemaRegistry(sr).WithSubjectNameStrategy(TopicRecordNameStrategy())
+	ser, _ := ProtobufSchemaSerializer[*wrapperspb.StringValue](cfg)
+	des, _ := ProtobufSchemaDeserializer[*wrapperspb.StringValue](cfg)
+
+	// ACT
+	b, err := ser("topic", wrapperspb.String("value"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	got, err := des("topic", b)
+
+	// ASSERT
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if got.GetValue() != "value" {
+		t.Errorf("wanted %q, got %q", "value", got.GetValue())
+	}
+	if subjects, _ := sr.GetAllSubjects(); len(subjects) != 1 || subjects[0] != "topic-google.protobuf.StringValue" {
+		t.Errorf("wanted subject %q, got %v", "topic-google.protobuf.StringValue", subjects)
+	}
+}
+
+func TestThatProtobufSchemaDeserializerReturnsErrorForInvalidWireFormat(t *testing.T) {
+	// ARRANGE
+	des, _ := ProtobufSchemaDeserializer[*wrapperspb.StringValue](NewConfig().WithSchemaRegistry(mock.SchemaRegistry()))
+
+	// ACT
+	_, err := des("topic", []byte{1, 2})
+
+	// ASSERT
+	if err != ErrInvalidWireFormat {
+		t.Errorf("wanted %v, got %v", ErrInvalidWireFormat, err)
+	}
+}
+
+func TestThatProtobufSchemaSerdesReturnAnErrorForAnInterfaceType(t *testing.T) {
+	// ARRANGE
+	cfg := NewConfig().WithSchemaRegistry(mock.SchemaRegistry())
+	wanted := ErrInvalidProtobufType{Type: "protoreflect.ProtoMessage"}
+
+	t.Run("serializer", func(t *testing.T) {
+		// ACT
+		_, err := ProtobufSchemaSerializer[proto.Message](cfg)
+
+		// ASSERT
+		if err != wanted {
+			t.Errorf("wanted %v, got %v", wanted, err)
+		}
+	})
+
+	t.Run("deserializer", func(t *testing.T) {
+		// ACT
+		_, err := ProtobufSchemaDeserializer[proto.Message](cfg)
+
+		// ASSERT
+		if err != wanted {
+			t.Errorf("wanted %v, got %v", wanted, err)
+		}
+	})
+}
@@ -0,0 +1,155 @@
+package kafka
+
+import (
+	"context"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+)
+
+// PartitionsFunc is called by a Consumer when partitions are assigned to or
+// revoked from the consumer.  The context is the context with which handlers
+// are called.
+//
+// The func is called on the goroutine reading messages, so no messages are
+// consumed until it returns.  If it returns an error, the Consumer stops and
+// Run returns the error.
+type PartitionsFunc func(context.Context, []kafka.TopicPartition) error
+
+// rebalanceCb returns the callback through which the consumer is notified of
+// partitions being assigned or revoked.
+//
+// Assigned partitions are assigned by the callback (incrementally, with
+// cooperative rebalancing), so that any start offsets are applied.  Revoked
+// partitions are unassigned by the kafka consumer itself once the callback
+// returns.
+func (c *Consumer) rebalanceCb(hctx context.Context) kafka.RebalanceCb {
+	return func(_ *kafka.Consumer, ev kafka.Event) error {
+		var err error
+		switch ev := ev.(type) {
+		case kafka.AssignedPartitions:
+			err = c.assigned(hctx, ev.Partitions, c.rebalanceAssign)
+		case kafka.RevokedPartitions:
+			err = c.revoked(hctx, ev.Partitions)
+		}
+		if err != nil && c.stopErr == nil {
+			c.stopErr = err
+		}
+		return err
+	}
+}
+
+// assigned is called when partitions are assigned to the consumer.  The start
+// offsets of any newly assigned partitions are resolved and the partitions
+// assigned, at those offsets, using the specified func before the
+// PartitionsFunc (if any) is called.
+func (c *Consumer) assigned(ctx context.Context, tpa []kafka.TopicPartition, assign func([]kafka.TopicPartition) error) error {
+	for _, tp := range tpa {
+		c.partitions[partitionOf(tp)] = true
+	}
+
+	resolved, err := c.resolveStartOffsets(tpa)
+	if err != nil {
+		return err
+	}
+	if err := assign(resolved); err != nil {
+		return err
+	}
+
+	if c.config.onAssigned == nil {
+		return nil
+	}
+	return c.config.onAssigned(ctx, tpa)
+}
+
+// rebalanceAssign assigns partitions in a rebalance, incrementally if the
+// consumer group uses the cooperative rebalance protocol.
+func (c *Consumer) rebalanceAssign(tpa []kafka.TopicPartition) error {
+	if c.hooks.GetRebalanceProtocol(c.consumer) == "COOPERATIVE" {
+		return c.hooks.IncrementalAssign(c.consumer, tpa)
+	}
+	return c.hooks.Assign(c.consumer, tpa)
+}
+
+// revoked is called when partitions are revoked from the consumer.  The
+// partitions are drained before being released, with the offsets of completed
+// messages committed.
+func (c *Consumer) revoked(ctx context.Context, tpa []kafka.TopicPartition) error {
+	revoked := map[partition]bool{}
+	for _, tp := range tpa {
+		revoked[partitionOf(tp)] = true
+	}
+
+	if err := c.drain(revoked); err != nil {
+		return err
+	}
+
+	if c.config.onRevoked != nil {
+		if err := c.config.onRevoked(ctx, tpa); err != nil {
+			return err
+		}
+	}
+
+	return c.forget(revoked)
+}
+
+// drain allows messages in-flight on the specified partitions to complete and
+// commits the offsets of all completed messages.  Messages from the partitions
+// accumulated in incomplete batches are discarded; they will be consumed again
+// from the committed offset.
+func (c *Consumer) drain(partitions map[partition]bool) error {
+	c.discardBatched(partitions)
+
+	if c.workers != nil {
+		for p := range partitions {
+			for c.offsets.inFlightOn(p) > 0 {
+				if err := c.workers.await(c.completed); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	// A failed commit does not stop the consumer; the messages will be
+	// consumed again by whichever consumer next consumes the partitions
+	if err := c.commitAll(); err != nil {
+		c.logger.Printf("commit of offsets on draining partitions failed: %v", err)
+	}
+
+	return nil
+}
+
+// forget discards any state held for the specified partitions, first resuming
+// any that are paused.
+func (c *Consumer) forget(partitions map[partition]bool) error {
+	if err := c.unhold(partitions); err != nil {
+		return err
+	}
+	for p := range partitions {
+		c.offsets.remove(p)
+		delete(c.paused, p)
+		delete(c.throttled, p)
+		delete(c.held, p)
+		delete(c.rewound, p)
+		delete(c.redeliveries, p)
+		delete(c.partitions, p)
+	}
+	return nil
+}
+
+// discardBatched removes any messages on the specified partitions from the
+// batches being accumulated for batch handlers.
+func (c *Consumer) discardBatched(partitions map[partition]bool) {
+	for topic, b := range c.batches {
+		msgs := b.msgs[:0]
+		for _, msg := range b.msgs {
+			if !partitions[partitionOf(msg.TopicPartition)] {
+				msgs = append(msgs, msg)
+			}
+		}
+		b.msgs = msgs
+
+		if len(b.msgs) == 0 {
+			delete(c.batches, topic)
+		}
+	}
+}
@@ -0,0 +1,213 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+
+	"github.com/deltics/go-kafka/mock"
+)
+
+// partitionMessage returns a message on a specified partition and offset of a
+// topic.
+func partitionMessage(topic string, id int32, offset kafka.Offset) *kafka.Message {
+	msg := StringMessage(topic, fmt.Sprintf("%d:%d", id, offset))
+	msg.TopicPartition.Partition = id
+	msg.TopicPartition.Offset = offset
+	return msg
+}
+
+func TestThatTheOnPartitionsAssignedFuncIsCalled(t *testing.T) {
+	// MOCK
+	p := mock.ConsumerHooks()
+	p.Messages([]interface{}{
+		kafka.AssignedPartitions{Partitions: []kafka.TopicPartition{
+			partition{topic: "topicA", id: 1}.topicPartition(kafka.OffsetInvalid),
+		}},
+	})
+
+	// ARRANGE
+	var assigned []kafka.TopicPartition
+	cfg := NewConfig().WithHooks(p).
+		WithOnPartitionsAssigned(func(ctx context.Context, tpa []kafka.TopicPartition) error {
+			assigned = tpa
+			return nil
+		}).
+		WithMessageHandler("topicA", func(ctx context.Context, msg *kafka.Message) error { return nil })
+
+	c, _ := NewConsumer(cfg)
+
+	// ACT
+	c.Run(context.Background())
+
+	// ASSERT
+	if len(assigned) != 1 || *assigned[0].Topic != "topicA" || assigned[0].Partition != 1 {
+		t.Errorf("wanted topicA[1] assigned, got %v", assigned)
+	}
+}
+
+func TestThatRunReturnsAnErrorFromAPartitionsFunc(t *testing.T) {
+	// MOCK
+	p := mock.ConsumerHooks()
+	p.Messages([]interface{}{
+		kafka.AssignedPartitions{},
+		StringMessage("topicA", "message"),
+	})
+
+	// ARRANGE
+	fnErr := errors.New("failed")
+	handled := false
+	cfg := NewConfig().WithHooks(p).
+		WithOnPartitionsAssigned(func(ctx context.Context, tpa []kafka.TopicPartition) error {
+			return fnErr
+		}).
+		WithMessageHandler("topicA", func(ctx context.Context, msg *kafka.Message) error {
+			handled = true
+			return nil
+		})
+
+	c, _ := NewConsumer(cfg)
+
+	// ACT
+	err := c.Run(context.Background())
+
+	// ASSERT
+	if !errors.Is(err, fnErr) {
+		t.Errorf("wanted %v, got %v", fnErr, err)
+	}
+	if handled {
+		t.Error("message was handled")
+	}
+}
+
+func TestThatInFlightMessagesAreCompletedAndCommittedBeforePartitionsAreRevoked(t *testing.T) {
+	// MOCK
+	mu := sync.Mutex{}
+	committed := map[int32]kafka.Offset{}
+
+	p := mock.ConsumerHooks()
+	p.Funcs().CommitOffset = func(c *kafka.Consumer, tpa []kafka.TopicPartition) ([]kafka.TopicPartition, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		for _, tp := range tpa {
+			committed[tp.Partition] = tp.Offset
+		}
+		return tpa, nil
+	}
+	p.Messages([]interface{}{
+		partitionMessage("topicA", 0, 0),
+		partitionMessage("topicA", 0, 1),
+		partitionMessage("topicA", 0, 2),
+		kafka.RevokedPartitions{Partitions: []kafka.TopicPartition{
+			partition{topic: "topicA", id: 0}.topicPartition(kafka.OffsetInvalid),
+		}},
+	})
+
+	// ARRANGE
+	handled := 0
+	var committedOnRevoke kafka.Offset
+	cfg := NewConfig().WithHooks(p).
+		WithAutoCommit(false).
+		WithConcurrency(2).
+		WithOnPartitionsRevoked(func(ctx context.Context, tpa []kafka.TopicPartition) error {
+			mu.Lock()
+			defer mu.Unlock()
+			committedOnRevoke = committed[0]
+			return nil
+		}).
+		WithMessageHandler("topicA", func(ctx context.Context, msg *kafka.Message) error {
+			time.Sleep(10 * time.Millisecond)
+			mu.Lock()
+			defer mu.Unlock()
+			handled++
+			return nil
+		})
+
+	c, _ := NewConsumer(cfg)
+
+	// ACT
+	c.Run(context.Background())
+
+	// ASSERT
+	if handled != 3 {
+		t.Errorf("wanted %d messages handled, got %d", 3, handled)
+	}
+	wanted := kafka.Offset(3)
+	got := committedOnRevoke
+	if wanted != got {
+		t.Errorf("wanted offset %v committed on revoke, got %v", wanted, got)
+	}
+}
+
+func TestThatBatchedMessagesOnRevokedPartitionsAreDiscarded(t *testing.T) {
+	// MOCK
+	p := mock.ConsumerHooks()
+	p.Messages([]interface{}{
+		partitionMessage("topicA", 0, 0),
+		partitionMessage("topicA", 1, 0),
+		kafka.RevokedPartitions{Partitions: []kafka.TopicPartition{
+			partition{topic: "topicA", id: 0}.topicPartition(kafka.OffsetInvalid),
+		}},
+		partitionMessage("topicA", 1, 1),
+		partitionMessage("topicA", 1, 2),
+	})
+
+	// ARRANGE
+	batches := [][]string{}
+	cfg := NewConfig().WithHooks(p).
+		WithBatchMessageHandler("topicA", func(ctx context.Context, msgs []*kafka.Message) error {
+			batch := []string{}
+			for _, msg := range msgs {
+				batch = append(batch, string(msg.Value))
+			}
+			batches = append(batches, batch)
+			return nil
+		}, 3, time.Hour)
+
+	c, _ := NewConsumer(cfg)
+
+	// ACT
+	c.Run(context.Background())
+
+	// ASSERT
+	wanted := "[[1:0 1:1 1:2]]"
+	got := fmt.Sprintf("%v", batches)
+	if wanted != got {
+		t.Errorf("wanted batches %v, got %v", wanted, got)
+	}
+}
+
+func TestThatAPausedPartitionIsResumedWhenItIsRevoked(t *testing.T) {
+	// MOCK
+	calls := make(chan string, 10)
+	p := pauseHooks(calls)
+	tpa := []kafka.TopicPartition{partition{topic: "topicA", id: 0}.topicPartition(kafka.OffsetInvalid)}
+	p.Messages([]interface{}{
+		kafka.AssignedPartitions{Partitions: tpa},
+		10 * time.Millisecond,
+		kafka.RevokedPartitions{Partitions: tpa},
+		10 * time.Millisecond,
+	})
+
+	// ARRANGE
+	cfg := NewConfig().WithHooks(p).
+		WithMessageHandler("topicA", func(ctx context.Context, msg *kafka.Message) error { return nil })
+
+	c, _ := NewConsumer(cfg)
+	c.Pause("topicA")
+
+	// ACT
+	c.Run(context.Background())
+	close(calls)
+
+	// ASSERT
+	wanted := "[pause [topicA[0]@unset] resume [topicA[0]@unset]]"
+	if got := fmt.Sprintf("%v", received(calls)); wanted != got {
+		t.Errorf("wanted %v, got %v", wanted, got)
+	}
+}
@@ -0,0 +1,177 @@
+package kafka
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+)
+
+// Headers added to a message forwarded to a retry topic.
+const (
+	RetryTopicHeader     = "retry.topic"      // the topic on which the message was originally received
+	RetryNotBeforeHeader = "retry.not-before" // the time (unix ms) before which the message should not be retried
+	RetryAttemptsHeader  = "retry.attempts"   // the number of attempts made to handle the message
+)
+
+// RetryTier identifies a topic to which failed messages are forwarded and the
+// delay before messages on that topic are retried.
+type RetryTier struct {
+	Topic string
+	Delay time.Duration
+}
+
+// RetryTopic returns a RetryTier for the specified topic and delay.
+func RetryTopic(topic string, delay time.Duration) RetryTier {
+	return RetryTier{Topic: topic, Delay: delay}
+}
+
+// RetryTopics returns a HandlerOption which configures retry topics for the
+// handler.  When a message fails and the FailurePolicy returns
+// RetryMessageLater, the message is forwarded to the next tier in turn.  Once
+// all tiers are exhausted, the message is sent to the dead-letter.
+//
+// The Consumer subscribes to the retry topics, calling the handler for messages
+// received on them once the delay for each message has elapsed.  Until then,
+// the partition on which the message was received is paused, so that retries
+// do not block the consumption of other topics and partitions.
+//
+// e.g.
+//
+//	cfg.WithMessageHandler("orders", handler, RetryTopics(
+//		RetryTopic("orders.retry.1m", time.Minute),
+//		RetryTopic("orders.retry.10m", 10*time.Minute),
+//	))
+func RetryTopics(tiers ...RetryTier) HandlerOption {
+	return func(h *messageHandler) {
+		h.retryTiers = append([]RetryTier{}, tiers...)
+	}
+}
+
+// RetryFailedMessagesLater returns a FailurePolicy which forwards any failed
+// message to the next retry topic configured for the handler (or to the
+// dead-letter, if there are no further retry topics).
+//
+// This is the default policy for handlers with retry topics.
+func RetryFailedMessagesLater() FailurePolicy {
+	return func(context.Context, *Failure) FailureAction { return RetryMessageLater }
+}
+
+// route identifies the handler for a topic and, if the topic is a retry topic,
+// the tier of the topic (tier 0 is the handler topic; tier 1 the first
+// retry topic etc).
+type route struct {
+	handler messageHandler
+	tier    int
+}
+
+// retryLater forwards a failed message to a retry tier, waiting for the message
+// to be delivered until the context is done.
+func (c *Consumer) retryLater(ctx context.Context, tier RetryTier, f *Failure) error {
+	if _, err := c.producer.MustProduceContext(ctx, retryTopicMessage(tier, f, c.now())); err != nil {
+		return ErrRetryFailed{Failure: f, Topic: tier.Topic, Err: err}
+	}
+	return nil
+}
+
+// deferRetry defers a message received on a retry topic that is not yet due.
+// The partition is paused until the message is due and the consumer position
+// for the partition is reset to the message so that it is received again when
+// the partition is resumed.
+func (c *Consumer) deferRetry(msg *kafka.Message, due time.Time) error {
+	p := partitionOf(msg.TopicPartition)
+
+	c.paused[p] = due
+	if err := c.hold(p); err != nil {
+		return err
+	}
+	return c.rewind(msg)
+}
+
+// resumeRetries resumes any paused retry topic partitions for which the next
+// message is due at the specified time (unless paused for some other reason).
+func (c *Consumer) resumeRetries(now time.Time) error {
+	for p, due := range c.paused {
+		if now.Before(due) {
+			continue
+		}
+		delete(c.paused, p)
+		if err := c.release(p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// retryTopicMessage returns a copy of a failed message to be forwarded to a
+// retry tier.  The copy retains the key, value and headers of the original
+// message, with headers identifying the original topic, the number of attempts
+// and the time before which it should not be retried.
+func retryTopicMessage(tier RetryTier, f *Failure, now time.Time) *kafka.Message {
+	msg := f.Message
+	topic := originalTopic(msg)
+
+	headers := make([]kafka.Header, 0, len(msg.Headers)+3)
+	for _, h := range msg.Headers {
+		switch h.Key {
+		case RetryTopicHeader, RetryNotBeforeHeader, RetryAttemptsHeader:
+			continue
+		}
+		headers = append(headers, h)
+	}
+	headers = append(headers,
+		kafka.Header{Key: RetryTopicHeader, Value: []byte(topic)},
+		kafka.Header{Key: RetryNotBeforeHeader, Value: []byte(strconv.FormatInt(now.Add(tier.Delay).UnixMilli(), 10))},
+		kafka.Header{Key: RetryAttemptsHeader, Value: []byte(strconv.Itoa(f.Attempts))},
+	)
+
+	return &kafka.Message{
+		TopicPartition: kafka.TopicPartition{
+			Topic:     &tier.Topic,
+			Partition: kafka.PartitionAny,
+		},
+		Key:     msg.Key,
+		Value:   msg.Value,
+		Headers: headers,
+	}
+}
+
+// originalTopic returns the topic on which a message was originally received:
+// the topic identified by the retry topic header of a message received on a
+// retry topic, otherwise the topic of the message.
+func originalTopic(msg *kafka.Message) string {
+	if topic := headerValue(msg, RetryTopicHeader); topic != "" {
+		return topic
+	}
+	return *msg.TopicPartition.Topic
+}
+
+// headerValue returns the value of the specified header of a message (or an
+// empty string if the message does not have the header).
+func headerValue(msg *kafka.Message, key string) string {
+	for _, h := range msg.Headers {
+		if h.Key == key {
+			return string(h.Value)
+		}
+	}
+	return ""
+}
+
+// retryAttempts returns the number of attempts recorded by the retry attempts
+// header of a message.
+func retryAttempts(msg *kafka.Message) int {
+	n, _ := strconv.Atoi(headerValue(msg, RetryAttemptsHeader))
+	return n
+}
+
+// retryNotBefore returns the time before which a message should not be retried
+// according to its retry not-before header.  If the message has no (valid)
+// not-before header the zero time is returned.
+func retryNotBefore(msg *kafka.Message) time.Time {
+	ms, err := strconv.ParseInt(headerValue(msg, RetryNotBeforeHeader), 10, 64)
+	if err != nil {
+		return time.Time{}
+	}
+	return time.UnixMilli(ms)
+}
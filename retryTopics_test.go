@@ -0,0 +1,348 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+
+	"github.com/deltics/go-kafka/mock"
+)
+
+// retryMessage returns a message received on a retry topic, with the headers
+// of a message forwarded to that topic.
+func retryMessage(topic string, original string, attempts int, notBefore time.Time) *kafka.Message {
+	msg := StringMessage(topic, "message")
+	msg.TopicPartition.Partition = 1
+	msg.TopicPartition.Offset = 10
+	msg.Headers = []kafka.Header{
+		{Key: RetryTopicHeader, Value: []byte(original)},
+		{Key: RetryNotBeforeHeader, Value: []byte(strconv.FormatInt(notBefore.UnixMilli(), 10))},
+		{Key: RetryAttemptsHeader, Value: []byte(strconv.Itoa(attempts))},
+	}
+	return msg
+}
+
+// producedHeaders returns the headers of a message as a map.
+func producedHeaders(msg *kafka.Message) map[string]string {
+	headers := map[string]string{}
+	for _, h := range msg.Headers {
+		headers[h.Key] = string(h.Value)
+	}
+	return headers
+}
+
+func retryConfig(ch interface{}, ph *[]*kafka.Message, fn MessageHandler) *config {
+	hk := mock.ProducerHooks()
+	hk.Funcs().Produce = func(p *kafka.Producer, m *kafka.Message, c chan kafka.Event) error {
+		*ph = append(*ph, m)
+		go func() { c <- m }()
+		return nil
+	}
+
+	return NewConfig().WithHooks(ch).
+		WithProducerHooks(hk).
+		WithDeadLetterTopic("orders.dlq").
+		WithMessageHandler("orders", fn, RetryTopics(
+			RetryTopic("orders.retry.1m", time.Minute),
+			RetryTopic("orders.retry.10m", 10*time.Minute),
+		))
+}
+
+func TestThatTheConsumerSubscribesToRetryTopics(t *testing.T) {
+	// MOCK
+	topics := map[string]bool{}
+
+	ch := mock.ConsumerHooks()
+	ch.Funcs().Subscribe = func(c *kafka.Consumer, ta []string, rcb kafka.RebalanceCb) error {
+		for _, t := range ta {
+			topics[t] = true
+		}
+		return nil
+	}
+
+	// ARRANGE
+	produced := []*kafka.Message{}
+	cfg := retryConfig(ch, &produced, func(context.Context, *kafka.Message) error { return nil })
+	c, _ := NewConsumer(cfg)
+
+	// ACT
+	c.Run(context.Background())
+
+	// ASSERT
+	for _, topic := range []string{"orders", "orders.retry.1m", "orders.retry.10m"} {
+		if !topics[topic] {
+			t.Errorf("consumer did not subscribe to %s", topic)
+		}
+	}
+}
+
+func TestThatAFailedMessageIsForwardedToTheFirstRetryTopic(t *testing.T) {
+	// MOCK
+	ch := mock.ConsumerHooks()
+	ch.Messages([]interface{}{
+		StringMessage("orders", "message"),
+	})
+
+	// ARRANGE
+	produced := []*kafka.Message{}
+	cfg := retryConfig(ch, &produced, func(context.Context, *kafka.Message) error { return errors.New("failed") })
+	c, _ := NewConsumer(cfg)
+
+	// ACT
+	before := time.Now()
+	c.Run(context.Background())
+
+	// ASSERT
+	if len(produced) != 1 {
+		t.Fatalf("wanted %d message(s) produced, got %d", 1, len(produced))
+	}
+	if got := *produced[0].TopicPartition.Topic; got != "orders.retry.1m" {
+		t.Errorf("wanted message forwarded to %q, got %q", "orders.retry.1m", got)
+	}
+
+	headers := producedHeaders(produced[0])
+	if got := headers[RetryTopicHeader]; got != "orders" {
+		t.Errorf("wanted %s header %q, got %q", RetryTopicHeader, "orders", got)
+	}
+	if got := headers[RetryAttemptsHeader]; got != "1" {
+		t.Errorf("wanted %s header %q, got %q", RetryAttemptsHeader, "1", got)
+	}
+	if due := retryNotBefore(produced[0]); due.Before(before.Add(time.Minute).Truncate(time.Millisecond)) {
+		t.Errorf("wanted message not before %v, got %v", before.Add(time.Minute), due)
+	}
+}
+
+func TestThatAFailedRetryIsForwardedToTheNextRetryTopic(t *testing.T) {
+	// MOCK
+	ch := mock.ConsumerHooks()
+	ch.Messages([]interface{}{
+		retryMessage("orders.retry.1m", "orders", 1, time.Now().Add(-time.Second)),
+	})
+
+	// ARRANGE
+	produced := []*kafka.Message{}
+	cfg := retryConfig(ch, &produced, func(context.Context, *kafka.Message) error { return errors.New("failed") })
+	c, _ := NewConsumer(cfg)
+
+	// ACT
+	c.Run(context.Background())
+
+	// ASSERT
+	if len(produced) != 1 {
+		t.Fatalf("wanted %d message(s) produced, got %d", 1, len(produced))
+	}
+	if got := *produced[0].TopicPartition.Topic; got != "orders.retry.10m" {
+		t.Errorf("wanted message forwarded to %q, got %q", "orders.retry.10m", got)
+	}
+
+	headers := producedHeaders(produced[0])
+	if got := headers[RetryTopicHeader]; got != "orders" {
+		t.Errorf("wanted %s header %q, got %q", RetryTopicHeader, "orders", got)
+	}
+	if got := headers[RetryAttemptsHeader]; got != "2" {
+		t.Errorf("wanted %s header %q, got %q", RetryAttemptsHeader, "2", got)
+	}
+}
+
+func TestThatAFailedMessageOnTheLastRetryTopicIsSentToTheDeadLetterTopic(t *testing.T) {
+	// MOCK
+	ch := mock.ConsumerHooks()
+	ch.Messages([]interface{}{
+		retryMessage("orders.retry.10m", "orders", 2, time.Now().Add(-time.Second)),
+	})
+
+	// ARRANGE
+	produced := []*kafka.Message{}
+	cfg := retryConfig(ch, &produced, func(context.Context, *kafka.Message) error { return errors.New("failed") })
+	c, _ := NewConsumer(cfg)
+
+	// ACT
+	c.Run(context.Background())
+
+	// ASSERT
+	if len(produced) != 1 {
+		t.Fatalf("wanted %d message(s) produced, got %d", 1, len(produced))
+	}
+	if got := *produced[0].TopicPartition.Topic; got != "orders.dlq" {
+		t.Errorf("wanted message sent to %q, got %q", "orders.dlq", got)
+	}
+	if got := producedHeaders(produced[0])[DeadLetterAttemptsHeader]; got != "3" {
+		t.Errorf("wanted %s header %q, got %q", DeadLetterAttemptsHeader, "3", got)
+	}
+	if got := producedHeaders(produced[0])[DeadLetterTopicHeader]; got != "orders" {
+		t.Errorf("wanted %s header %q, got %q", DeadLetterTopicHeader, "orders", got)
+	}
+}
+
+func TestThatARetryThatIsNotDuePausesThePartition(t *testing.T) {
+	// MOCK
+	paused := []kafka.TopicPartition{}
+	seeked := []kafka.TopicPartition{}
+
+	ch := mock.ConsumerHooks()
+	ch.Funcs().Pause = func(c *kafka.Consumer, tpa []kafka.TopicPartition) error {
+		paused = append(paused, tpa...)
+		return nil
+	}
+	ch.Funcs().Seek = func(c *kafka.Consumer, tp kafka.TopicPartition, timeoutMs int) error {
+		seeked = append(seeked, tp)
+		return nil
+	}
+	ch.Messages([]interface{}{
+		retryMessage("orders.retry.1m", "orders", 1, time.Now().Add(time.Minute)),
+	})
+
+	// ARRANGE
+	handled := false
+	produced := []*kafka.Message{}
+	cfg := retryConfig(ch, &produced, func(context.Context, *kafka.Message) error { handled = true; return nil })
+	c, _ := NewConsumer(cfg)
+
+	// ACT
+	c.Run(context.Background())
+
+	// ASSERT
+	if handled {
+		t.Error("message was handled before it was due")
+	}
+	if len(paused) != 1 || *paused[0].Topic != "orders.retry.1m" || paused[0].Partition != 1 {
+		t.Errorf("wanted partition orders.retry.1m[1] paused, got %v", paused)
+	}
+	if len(seeked) != 1 || seeked[0].Offset != 10 {
+		t.Errorf("wanted seek to offset 10, got %v", seeked)
+	}
+}
+
+func TestThatARetryThatIsDueIsHandled(t *testing.T) {
+	// MOCK
+	ch := mock.ConsumerHooks()
+	ch.Messages([]interface{}{
+		retryMessage("orders.retry.1m", "orders", 1, time.Now().Add(-time.Second)),
+	})
+
+	// ARRANGE
+	handled := false
+	produced := []*kafka.Message{}
+	cfg := retryConfig(ch, &produced, func(context.Context, *kafka.Message) error { handled = true; return nil })
+	c, _ := NewConsumer(cfg)
+
+	// ACT
+	c.Run(context.Background())
+
+	// ASSERT
+	if !handled {
+		t.Error("message was not handled")
+	}
+}
+
+func TestThatPausedRetryPartitionsAreResumedWhenDue(t *testing.T) {
+	// MOCK
+	resumed := []kafka.TopicPartition{}
+
+	ch := mock.ConsumerHooks()
+	ch.Funcs().Resume = func(c *kafka.Consumer, tpa []kafka.TopicPartition) error {
+		resumed = append(resumed, tpa...)
+		return nil
+	}
+
+	// ARRANGE
+	produced := []*kafka.Message{}
+	cfg := retryConfig(ch, &produced, func(context.Context, *kafka.Message) error { return nil })
+	c, _ := NewConsumer(cfg)
+
+	now := time.Now()
+	c.paused[partition{topic: "orders.retry.1m", id: 0}] = now.Add(-time.Second)
+	c.paused[partition{topic: "orders.retry.10m", id: 0}] = now.Add(time.Second)
+
+	// ACT
+	err := c.resumeRetries(now)
+
+	// ASSERT
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if len(resumed) != 1 || *resumed[0].Topic != "orders.retry.1m" {
+		t.Errorf("wanted orders.retry.1m resumed, got %v", resumed)
+	}
+	if len(c.paused) != 1 {
+		t.Errorf("wanted %d partition(s) still paused, got %d", 1, len(c.paused))
+	}
+}
+
+func TestThatForwardingToARetryTopicStopsWhenTheDrainTimeoutExpires(t *testing.T) {
+	// MOCK
+	ph := mock.ProducerHooks()
+	ph.Funcs().Produce = func(*kafka.Producer, *kafka.Message, chan kafka.Event) error {
+		// the message is never delivered
+		return nil
+	}
+
+	ch := mock.ConsumerHooks()
+	ch.Messages([]interface{}{
+		StringMessage("orders", "message"),
+	})
+
+	// ARRANGE
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	cfg := NewConfig().WithHooks(ch).
+		WithProducerHooks(ph).
+		WithDrainTimeout(10*time.Millisecond).
+		WithMessageHandler("orders", func(context.Context, *kafka.Message) error {
+			cancel()
+			return errors.New("failed")
+		}, RetryTopics(RetryTopic("orders.retry.1m", time.Minute)))
+
+	c, _ := NewConsumer(cfg)
+
+	// ACT
+	result := make(chan error, 1)
+	go func() { result <- c.Run(ctx) }()
+
+	// ASSERT
+	select {
+	case err := <-result:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("wanted %v, got %v", context.Canceled, err)
+		}
+		if !errors.As(err, &ErrRetryFailed{}) {
+			t.Errorf("wanted %T, got %v", ErrRetryFailed{}, err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Run did not return when the drain timeout expired")
+	}
+}
+
+func TestThatRetriesAreScheduledUsingTheConsumerClock(t *testing.T) {
+	// MOCK
+	clock := &fakeClock{t: time.Now().Add(time.Hour)}
+
+	ch := mock.ConsumerHooks()
+	ch.Messages([]interface{}{
+		// due in a minute by the wall clock, but overdue by the consumer clock
+		retryMessage("orders.retry.1m", "orders", 1, time.Now().Add(time.Minute)),
+	})
+
+	// ARRANGE
+	produced := []*kafka.Message{}
+	cfg := retryConfig(ch, &produced, func(context.Context, *kafka.Message) error { return errors.New("failed") })
+	c, _ := NewConsumer(cfg)
+	c.now = clock.now
+
+	// ACT
+	c.Run(context.Background())
+
+	// ASSERT
+	if len(produced) != 1 {
+		t.Fatalf("wanted %d message(s) produced, got %d", 1, len(produced))
+	}
+	wanted := clock.now().Add(10 * time.Minute).Truncate(time.Millisecond)
+	if got := retryNotBefore(produced[0]); !got.Equal(wanted) {
+		t.Errorf("wanted message not before %v, got %v", wanted, got)
+	}
+}
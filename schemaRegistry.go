@@ -0,0 +1,134 @@
+package kafka
+
+import (
+	"encoding/binary"
+	"errors"
+
+	"github.com/confluentinc/confluent-kafka-go/schemaregistry"
+	"github.com/confluentinc/confluent-kafka-go/schemaregistry/serde"
+)
+
+// ErrInvalidWireFormat is returned by a schema registry Deserializer if the
+// value of a message is not in the Confluent wire format (a zero magic byte
+// followed by a 4-byte schema id).
+var ErrInvalidWireFormat = errors.New("invalid schema registry wire format")
+
+// wireMagicByte is the first byte of a value in the Confluent wire format.
+const wireMagicByte = 0
+
+// wireDecode returns the schema id and encoded data of a value in the
+// Confluent wire format.  The serdes of the schemaregistry package assume a
+// value is in the wire format, so values are checked before being passed to
+// them.
+func wireDecode(b []byte) (int, []byte, error) {
+	if len(b) < 5 || b[0] != wireMagicByte {
+		return 0, nil, ErrInvalidWireFormat
+	}
+	return int(binary.BigEndian.Uint32(b[1:5])), b[5:], nil
+}
+
+// SubjectNameStrategy determines the subject under which the schema of the
+// key or value of a message produced to a topic is registered, given the
+// fully-qualified name of the record (message type) described by the schema.
+type SubjectNameStrategy func(topic string, key bool, record string) string
+
+// TopicNameStrategy returns a SubjectNameStrategy which names subjects for the
+// topic: "<topic>-key" or "<topic>-value".
+//
+// This is the default strategy if no other is configured.
+func TopicNameStrategy() SubjectNameStrategy {
+	return func(topic string, key bool, _ string) string {
+		if key {
+			return topic + "-key"
+		}
+		return topic + "-value"
+	}
+}
+
+// RecordNameStrategy returns a SubjectNameStrategy which names subjects for the
+// fully-qualified name of the record, allowing different record types on the
+// same topic.
+func RecordNameStrategy() SubjectNameStrategy {
+	return func(_ string, _ bool, record string) string {
+		return record
+	}
+}
+
+// TopicRecordNameStrategy returns a SubjectNameStrategy which names subjects
+// for the topic and the fully-qualified name of the record:
+// "<topic>-<record>".
+func TopicRecordNameStrategy() SubjectNameStrategy {
+	return func(topic string, _ bool, record string) string {
+		return topic + "-" + record
+	}
+}
+
+// SchemaOption configures a schema registry Serializer or Deserializer.
+type SchemaOption func(*schemaSerde)
+
+// ForKeys returns a SchemaOption for a Serializer (or Deserializer) of message
+// keys (rather than values), determining the subject of the schema.
+func ForKeys() SchemaOption {
+	return func(s *schemaSerde) {
+		s.key = true
+	}
+}
+
+// schemaSerde holds the configuration of a schema registry Serializer or
+// Deserializer, from which the serdes of the schemaregistry package are
+// configured.
+type schemaSerde struct {
+	registry  schemaregistry.Client
+	subject   SubjectNameStrategy
+	useLatest bool
+	key       bool
+}
+
+// newSchemaSerde returns a schemaSerde using the schema registry configured on
+// a Config.
+func newSchemaSerde(cfg *config, opts []SchemaOption) *schemaSerde {
+	if cfg.schemaRegistry == nil {
+		panic("no schema registry configured")
+	}
+
+	s := &schemaSerde{
+		registry:  cfg.schemaRegistry,
+		subject:   cfg.subjectNameStrategy,
+		useLatest: cfg.useLatestSchema,
+	}
+	if s.subject == nil {
+		s.subject = TopicNameStrategy()
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// serdeType returns the serde.Type of the serde (key or value).
+func (s *schemaSerde) serdeType() serde.Type {
+	if s.key {
+		return serde.KeySerde
+	}
+	return serde.ValueSerde
+}
+
+// serializerConfig returns the serde.SerializerConfig for a serializer, which
+// either registers schemas or uses the latest schema for the subject.
+func (s *schemaSerde) serializerConfig() serde.SerializerConfig {
+	cfg := *serde.NewSerializerConfig()
+	cfg.AutoRegisterSchemas = !s.useLatest
+	cfg.UseLatestVersion = s.useLatest
+	return cfg
+}
+
+// subjectNameStrategy returns a serde.SubjectNameStrategyFunc naming subjects
+// for the specified record using the SubjectNameStrategy of the serde.  The
+// record is determined from the type of the values of the serde rather than
+// from the schema, since a deserializer names the subject of a schema before
+// the schema is known.
+func (s *schemaSerde) subjectNameStrategy(record string) serde.SubjectNameStrategyFunc {
+	return func(topic string, _ serde.Type, _ schemaregistry.SchemaInfo) (string, error) {
+		return s.subject(topic, s.key, record), nil
+	}
+}
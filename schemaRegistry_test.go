@@ -0,0 +1,58 @@
+package kafka
+
+import (
+	"testing"
+)
+
+func TestThatWireFormatIsDecoded(t *testing.T) {
+	// ACT
+	id, data, err := wireDecode([]byte{0, 0, 0, 1, 2, 'd', 'a', 't', 'a'})
+
+	// ASSERT
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if id != 258 {
+		t.Errorf("wanted id %d, got %d", 258, id)
+	}
+	if string(data) != "data" {
+		t.Errorf("wanted %q, got %q", "data", data)
+	}
+}
+
+func TestThatWireDecodeReturnsErrorForInvalidWireFormat(t *testing.T) {
+	t.Run("wrong magic byte", func(t *testing.T) {
+		if _, _, err := wireDecode([]byte{1, 0, 0, 0, 1}); err != ErrInvalidWireFormat {
+			t.Errorf("wanted %v, got %v", ErrInvalidWireFormat, err)
+		}
+	})
+
+	t.Run("too short", func(t *testing.T) {
+		if _, _, err := wireDecode([]byte{0, 0, 0}); err != ErrInvalidWireFormat {
+			t.Errorf("wanted %v, got %v", ErrInvalidWireFormat, err)
+		}
+	})
+}
+
+func TestThatSubjectNameStrategiesNameSubjects(t *testing.T) {
+	t.Run("topic", func(t *testing.T) {
+		if got := TopicNameStrategy()("orders", false, "com.example.Order"); got != "orders-value" {
+			t.Errorf("wanted %q, got %q", "orders-value", got)
+		}
+		if got := TopicNameStrategy()("orders", true, "com.example.Order"); got != "orders-key" {
+			t.Errorf("wanted %q, got %q", "orders-key", got)
+		}
+	})
+
+	t.Run("record", func(t *testing.T) {
+		if got := RecordNameStrategy()("orders", false, "com.example.Order"); got != "com.example.Order" {
+			t.Errorf("wanted %q, got %q", "com.example.Order", got)
+		}
+	})
+
+	t.Run("topic record", func(t *testing.T) {
+		if got := TopicRecordNameStrategy()("orders", false, "com.example.Order"); got != "orders-com.example.Order" {
+			t.Errorf("wanted %q, got %q", "orders-com.example.Order", got)
+		}
+	})
+}
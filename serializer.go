@@ -0,0 +1,41 @@
+package kafka
+
+import (
+	"encoding/json"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// Serializer encodes a value of type T as the key or value of a message to be
+// produced to a topic.
+type Serializer[T any] func(topic string, v T) ([]byte, error)
+
+// BytesSerializer returns a Serializer which returns a []byte unchanged.
+func BytesSerializer() Serializer[[]byte] {
+	return func(_ string, v []byte) ([]byte, error) {
+		return v, nil
+	}
+}
+
+// StringSerializer returns a Serializer which encodes a string as its bytes.
+func StringSerializer() Serializer[string] {
+	return func(_ string, v string) ([]byte, error) {
+		return []byte(v), nil
+	}
+}
+
+// JSONSerializer returns a Serializer which encodes a value as JSON, using
+// json.Marshal.
+func JSONSerializer[T any]() Serializer[T] {
+	return func(_ string, v T) ([]byte, error) {
+		return json.Marshal(v)
+	}
+}
+
+// ProtobufSerializer returns a Serializer which encodes a protobuf message of
+// type T (a pointer to a generated message type, e.g. *pb.Order).
+func ProtobufSerializer[T proto.Message]() Serializer[T] {
+	return func(_ string, v T) ([]byte, error) {
+		return proto.Marshal(v)
+	}
+}
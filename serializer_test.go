@@ -0,0 +1,78 @@
+package kafka
+
+import (
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func TestThatBytesSerializerReturnsTheValueUnchanged(t *testing.T) {
+	// ACT
+	got, err := BytesSerializer()("topic", []byte("value"))
+
+	// ASSERT
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if string(got) != "value" {
+		t.Errorf("wanted %q, got %q", "value", got)
+	}
+}
+
+func TestThatStringSerializerEncodesAString(t *testing.T) {
+	// ACT
+	got, err := StringSerializer()("topic", "value")
+
+	// ASSERT
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if string(got) != "value" {
+		t.Errorf("wanted %q, got %q", "value", got)
+	}
+}
+
+func TestThatJSONSerializerEncodesAValue(t *testing.T) {
+	type order struct {
+		Id string `json:"id"`
+	}
+
+	t.Run("encodes value", func(t *testing.T) {
+		// ACT
+		got, err := JSONSerializer[order]()("topic", order{Id: "a"})
+
+		// ASSERT
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+		wanted := `{"id":"a"}`
+		if string(got) != wanted {
+			t.Errorf("wanted %s, got %s", wanted, got)
+		}
+	})
+
+	t.Run("returns error for unsupported value", func(t *testing.T) {
+		// ACT
+		_, err := JSONSerializer[func()]()("topic", func() {})
+
+		// ASSERT
+		if err == nil {
+			t.Error("wanted error, got nil")
+		}
+	})
+}
+
+func TestThatProtobufSerializerEncodesAMessage(t *testing.T) {
+	// ACT
+	got, err := ProtobufSerializer[*wrapperspb.StringValue]()("topic", wrapperspb.String("value"))
+
+	// ASSERT
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	decoded := &wrapperspb.StringValue{}
+	if err := proto.Unmarshal(got, decoded); err != nil || decoded.GetValue() != "value" {
+		t.Errorf("wanted %q, got %q (error: %v)", "value", decoded.GetValue(), err)
+	}
+}
@@ -0,0 +1,104 @@
+package kafka
+
+import (
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+)
+
+// offsetsForTimesTimeout is the maximum time to wait for the offsets of
+// a start time to be resolved when partitions are assigned.
+const offsetsForTimesTimeout = 10 * time.Second
+
+// startPosition identifies the position from which a topic is consumed when
+// its partitions are first assigned to a Consumer: either an offset or, if
+// time is not zero, the earliest offset with a timestamp at or after that
+// time.
+type startPosition struct {
+	offset kafka.Offset
+	time   time.Time
+}
+
+// startPositionMap is a map of topic-name:startPosition.
+type startPositionMap map[string]startPosition
+
+func (spm startPositionMap) copy() startPositionMap {
+	copy := startPositionMap{}
+	for k, v := range spm {
+		copy[k] = v
+	}
+	return copy
+}
+
+// startPositionFor returns the start position configured for a topic, if any.
+// A position configured for the topic takes precedence over one configured
+// for all topics.
+func (c *config) startPositionFor(topic string) (startPosition, bool) {
+	if pos, ok := c.startFrom[topic]; ok {
+		return pos, true
+	}
+	pos, ok := c.startFrom[""]
+	return pos, ok
+}
+
+// withStartPosition returns a Config with a start position for the
+// specified topics (or for all topics, if none are specified).
+func (c *config) withStartPosition(pos startPosition, topics []string) *config {
+	r := c.copy()
+	if len(topics) == 0 {
+		topics = []string{""}
+	}
+	for _, t := range topics {
+		r.startFrom[t] = pos
+	}
+	return r
+}
+
+// resolveStartOffsets returns the specified (newly assigned) partitions with
+// the offset of any partition of a topic with a start position set to the
+// start offset, to be passed to Assign (or IncrementalAssign).  A start
+// position is applied only the first time a partition is assigned to the
+// consumer.  The offsets of start times are resolved using OffsetsForTimes.
+func (c *Consumer) resolveStartOffsets(tpa []kafka.TopicPartition) ([]kafka.TopicPartition, error) {
+	starting := map[partition]kafka.Offset{}
+	times := []kafka.TopicPartition{}
+	for _, tp := range tpa {
+		p := partitionOf(tp)
+		if c.started[p] {
+			continue
+		}
+		c.started[p] = true
+
+		pos, ok := c.config.startPositionFor(p.topic)
+		if !ok {
+			continue
+		}
+		if pos.time.IsZero() {
+			starting[p] = pos.offset
+			continue
+		}
+		times = append(times, p.topicPartition(kafka.Offset(pos.time.UnixMilli())))
+	}
+
+	if len(times) > 0 {
+		offsets, err := c.hooks.OffsetsForTimes(c.consumer, times, int(offsetsForTimesTimeout.Milliseconds()))
+		if err != nil {
+			return nil, err
+		}
+		for _, tp := range offsets {
+			if tp.Error != nil {
+				return nil, tp.Error
+			}
+			starting[partitionOf(tp)] = tp.Offset
+		}
+	}
+
+	resolved := make([]kafka.TopicPartition, len(tpa))
+	for i, tp := range tpa {
+		if offset, ok := starting[partitionOf(tp)]; ok {
+			tp.Offset = offset
+		}
+		resolved[i] = tp
+	}
+	return resolved, nil
+}
@@ -0,0 +1,173 @@
+package kafka
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+
+	"github.com/deltics/go-kafka/mock"
+)
+
+// assigned returns an AssignedPartitions event for the specified partitions of
+// a topic.
+func assigned(topic string, ids ...int32) kafka.AssignedPartitions {
+	ev := kafka.AssignedPartitions{}
+	for _, id := range ids {
+		ev.Partitions = append(ev.Partitions, partition{topic: topic, id: id}.topicPartition(kafka.OffsetInvalid))
+	}
+	return ev
+}
+
+// startTestConsumer returns a Consumer configured with the specified config
+// func and handlers for topicA and topicB, which records the partitions (and
+// offsets) assigned when the specified events are consumed, and the times for
+// which offsets are resolved.
+func startTestConsumer(fn func(*config) *config, calls *[]string, events ...interface{}) *Consumer {
+	p := mock.ConsumerHooks()
+	p.Funcs().Assign = func(c *kafka.Consumer, tpa []kafka.TopicPartition) error {
+		*calls = append(*calls, fmt.Sprintf("assign %v", tpa))
+		return nil
+	}
+	p.Funcs().IncrementalAssign = func(c *kafka.Consumer, tpa []kafka.TopicPartition) error {
+		*calls = append(*calls, fmt.Sprintf("incremental assign %v", tpa))
+		return nil
+	}
+	p.Funcs().OffsetsForTimes = func(c *kafka.Consumer, times []kafka.TopicPartition, timeoutMs int) ([]kafka.TopicPartition, error) {
+		offsets := []kafka.TopicPartition{}
+		for _, tp := range times {
+			*calls = append(*calls, fmt.Sprintf("time %s[%d]@%d", *tp.Topic, tp.Partition, tp.Offset))
+			tp.Offset = kafka.Offset(100 + tp.Partition)
+			offsets = append(offsets, tp)
+		}
+		return offsets, nil
+	}
+	p.Messages(events)
+
+	cfg := fn(NewConfig().WithHooks(p)).
+		WithMessageHandler("topicA", func(ctx context.Context, msg *kafka.Message) error { return nil }).
+		WithMessageHandler("topicB", func(ctx context.Context, msg *kafka.Message) error { return nil })
+
+	c, _ := NewConsumer(cfg)
+	return c
+}
+
+func TestThatPartitionsAreAssignedAtTheOffsetsForTheStartTime(t *testing.T) {
+	// ARRANGE
+	start := time.Date(2022, 9, 1, 9, 0, 0, 0, time.UTC)
+	calls := []string{}
+	c := startTestConsumer(func(cfg *config) *config {
+		return cfg.WithStartFromTime(start)
+	}, &calls, assigned("topicA", 0, 1))
+
+	// ACT
+	c.Run(context.Background())
+
+	// ASSERT
+	sort.Strings(calls)
+	wanted := fmt.Sprintf("[assign [topicA[0]@100 topicA[1]@101] time topicA[0]@%[1]d time topicA[1]@%[1]d]", start.UnixMilli())
+	got := fmt.Sprintf("%v", calls)
+	if wanted != got {
+		t.Errorf("wanted %v, got %v", wanted, got)
+	}
+}
+
+func TestThatATopicStartPositionOverridesTheStartPositionForAllTopics(t *testing.T) {
+	// ARRANGE
+	calls := []string{}
+	c := startTestConsumer(func(cfg *config) *config {
+		return cfg.WithStartFromEarliest().
+			WithStartFromOffset(42, "topicB")
+	}, &calls, assigned("topicA", 0), assigned("topicB", 0))
+
+	// ACT
+	c.Run(context.Background())
+
+	// ASSERT
+	wanted := "[assign [topicA[0]@beginning] assign [topicB[0]@42]]"
+	got := fmt.Sprintf("%v", calls)
+	if wanted != got {
+		t.Errorf("wanted %v, got %v", wanted, got)
+	}
+}
+
+func TestThatTheStartPositionIsAppliedOnlyWhenAPartitionIsFirstAssigned(t *testing.T) {
+	// ARRANGE
+	calls := []string{}
+	c := startTestConsumer(func(cfg *config) *config {
+		return cfg.WithStartFromLatest("topicA")
+	}, &calls,
+		assigned("topicA", 0),
+		kafka.RevokedPartitions{Partitions: assigned("topicA", 0).Partitions},
+		assigned("topicA", 0),
+	)
+
+	// ACT
+	c.Run(context.Background())
+
+	// ASSERT
+	wanted := "[assign [topicA[0]@end] assign [topicA[0]@unset]]"
+	got := fmt.Sprintf("%v", calls)
+	if wanted != got {
+		t.Errorf("wanted %v, got %v", wanted, got)
+	}
+}
+
+func TestThatPartitionsAreAssignedIncrementallyWithCooperativeRebalancing(t *testing.T) {
+	// MOCK
+	calls := []string{}
+
+	p := mock.ConsumerHooks()
+	p.Funcs().GetRebalanceProtocol = func(*kafka.Consumer) string { return "COOPERATIVE" }
+	p.Funcs().Assign = func(c *kafka.Consumer, tpa []kafka.TopicPartition) error {
+		calls = append(calls, fmt.Sprintf("assign %v", tpa))
+		return nil
+	}
+	p.Funcs().IncrementalAssign = func(c *kafka.Consumer, tpa []kafka.TopicPartition) error {
+		calls = append(calls, fmt.Sprintf("incremental assign %v", tpa))
+		return nil
+	}
+	p.Messages([]interface{}{assigned("topicA", 0, 1)})
+
+	// ARRANGE
+	cfg := NewConfig().WithHooks(p).
+		WithStartFromOffset(42, "topicA").
+		WithMessageHandler("topicA", func(ctx context.Context, msg *kafka.Message) error { return nil })
+
+	c, _ := NewConsumer(cfg)
+
+	// ACT
+	c.Run(context.Background())
+
+	// ASSERT
+	wanted := "[incremental assign [topicA[0]@42 topicA[1]@42]]"
+	got := fmt.Sprintf("%v", calls)
+	if wanted != got {
+		t.Errorf("wanted %v, got %v", wanted, got)
+	}
+}
+
+func TestThatAnAssignmentIsAssignedAtTheStartOffsets(t *testing.T) {
+	// ARRANGE
+	calls := []string{}
+	c := startTestConsumer(func(cfg *config) *config {
+		return cfg.WithStartFromEarliest("topicA").
+			WithAssignment(
+				partition{topic: "topicA", id: 0}.topicPartition(kafka.OffsetStored),
+				partition{topic: "topicB", id: 0}.topicPartition(kafka.OffsetStored),
+			)
+	}, &calls)
+
+	// ACT
+	c.Run(context.Background())
+
+	// ASSERT
+	wanted := "[assign [topicA[0]@beginning topicB[0]@stored]]"
+	got := fmt.Sprintf("%v", calls)
+	if wanted != got {
+		t.Errorf("wanted %v, got %v", wanted, got)
+	}
+}
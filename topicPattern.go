@@ -0,0 +1,78 @@
+package kafka
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// topicPattern is a handler registered for a topic pattern, i.e. a topic id
+// with a "^" prefix, which librdkafka treats as a regular expression matching
+// the names of the topics to be consumed.
+type topicPattern struct {
+	re      *regexp.Regexp
+	pattern string
+	prefix  string // the literal prefix of the pattern
+	handler messageHandler
+}
+
+// isTopicPattern returns true if a topic id is a pattern.
+func isTopicPattern(t string) bool {
+	return strings.HasPrefix(t, "^")
+}
+
+// patterns returns the topic patterns with handlers in order of specificity,
+// most specific first.  A pattern with a longer literal prefix is more specific
+// than one with a shorter prefix; where prefixes are the same length, the
+// longer pattern is the more specific.  If any pattern is not a valid regular
+// expression an ErrInvalidTopicPattern is returned.
+func (thm messageHandlerMap) patterns() ([]topicPattern, error) {
+	patterns := []topicPattern{}
+	for k, v := range thm {
+		if !isTopicPattern(k) {
+			continue
+		}
+		re, err := regexp.Compile(k)
+		if err != nil {
+			return nil, ErrInvalidTopicPattern{Pattern: k, Err: err}
+		}
+
+		// The literal prefix is not reported for an anchored expression so is
+		// determined from the expression without the "^" (which is valid if
+		// the anchored expression is)
+		prefix, _ := regexp.MustCompile(k[1:]).LiteralPrefix()
+		patterns = append(patterns, topicPattern{re: re, pattern: k, prefix: prefix, handler: v})
+	}
+
+	sort.Slice(patterns, func(i, j int) bool {
+		a, b := patterns[i], patterns[j]
+		if len(a.prefix) != len(b.prefix) {
+			return len(a.prefix) > len(b.prefix)
+		}
+		if len(a.pattern) != len(b.pattern) {
+			return len(a.pattern) > len(b.pattern)
+		}
+		return a.pattern < b.pattern
+	})
+
+	return patterns, nil
+}
+
+// route returns the handler route for a topic.  A handler registered for the
+// topic itself is used in preference to any pattern; otherwise the most
+// specific matching pattern is used and the route is cached for the topic.
+func (c *Consumer) route(topic string) (route, bool) {
+	if r, ok := c.routes[topic]; ok {
+		return r, true
+	}
+
+	for _, p := range c.patterns {
+		if p.re.MatchString(topic) {
+			r := route{handler: p.handler}
+			c.routes[topic] = r
+			return r, true
+		}
+	}
+
+	return route{}, false
+}
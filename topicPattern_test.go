@@ -0,0 +1,110 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+
+	"github.com/deltics/go-kafka/mock"
+)
+
+func TestThatTheConsumerDispatchesMessagesToTheMostSpecificHandler(t *testing.T) {
+	// MOCK
+	p := mock.ConsumerHooks()
+	p.Messages([]interface{}{
+		StringMessage("tenant-a-events", "message"),
+		StringMessage("tenant-b-events", "message"),
+		StringMessage("tenant-c-events", "message"),
+		StringMessage("tenant-a-events", "message"),
+	})
+
+	// ARRANGE
+	handled := map[string][]string{}
+	handler := func(id string) MessageHandler {
+		return func(ctx context.Context, msg *kafka.Message) error {
+			handled[id] = append(handled[id], *msg.TopicPartition.Topic)
+			return nil
+		}
+	}
+	cfg := NewConfig().WithHooks(p).
+		WithMessageHandler("^.*-events$", handler("events")).
+		WithMessageHandler("^tenant-.*-events$", handler("tenant")).
+		WithMessageHandler("tenant-c-events", handler("tenant-c"))
+
+	c, _ := NewConsumer(cfg)
+
+	// ACT
+	c.Run(context.Background())
+
+	// ASSERT
+	if len(handled["events"]) != 0 {
+		t.Errorf("wanted no messages handled by the least specific pattern, got %v", handled["events"])
+	}
+	if len(handled["tenant"]) != 3 {
+		t.Errorf("wanted %d messages handled by the most specific pattern, got %v", 3, handled["tenant"])
+	}
+	if len(handled["tenant-c"]) != 1 {
+		t.Errorf("wanted %d message handled by the topic handler, got %v", 1, handled["tenant-c"])
+	}
+}
+
+func Test_MessageHandlerMap_patterns(t *testing.T) {
+	handler := messageHandler{}
+	thm := messageHandlerMap{
+		"topic":          handler,
+		"^.*":            handler,
+		"^orders-.*":     handler,
+		"^orders-eu-.*":  handler,
+		"^orders-.*-v2$": handler,
+	}
+
+	patterns, err := thm.patterns()
+
+	t.Run("returns no error", func(t *testing.T) {
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+
+	t.Run("excludes topics", func(t *testing.T) {
+		wanted := 4
+		got := len(patterns)
+		if wanted != got {
+			t.Errorf("wanted %d patterns, got %d", wanted, got)
+		}
+	})
+
+	t.Run("orders patterns by specificity", func(t *testing.T) {
+		wanted := []string{"^orders-eu-.*", "^orders-.*-v2$", "^orders-.*", "^.*"}
+		for i, p := range patterns {
+			if wanted[i] != p.pattern {
+				t.Errorf("%d: wanted %q, got %q", i, wanted[i], p.pattern)
+			}
+		}
+	})
+}
+
+func TestThatNewConsumerReturnsAnErrorForAnInvalidTopicPattern(t *testing.T) {
+	// ARRANGE
+	created := false
+	p := mock.ConsumerHooks()
+	p.Funcs().Create = func(*kafka.ConfigMap) (*kafka.Consumer, error) {
+		created = true
+		return &kafka.Consumer{}, nil
+	}
+	cfg := NewConfig().WithHooks(p).
+		WithMessageHandler("^topic-(", func(context.Context, *kafka.Message) error { return nil })
+
+	// ACT
+	c, err := NewConsumer(cfg)
+
+	// ASSERT
+	if !errors.As(err, &ErrInvalidTopicPattern{}) {
+		t.Errorf("wanted %T, got %v", ErrInvalidTopicPattern{}, err)
+	}
+	if c != nil || created {
+		t.Error("wanted no consumer created")
+	}
+}
@@ -0,0 +1,240 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+)
+
+// initTransactionsTimeout is the maximum time to wait for transactions to be
+// initialised when a transactional producer is created.
+const initTransactionsTimeout = 30 * time.Second
+
+// commitRetryBackoff is the initial delay before retrying a commit which
+// failed with a retriable error, doubling with each attempt up to
+// maxCommitRetryBackoff.
+const commitRetryBackoff = 100 * time.Millisecond
+
+// maxCommitRetryBackoff is the upper limit on the delay between attempts to
+// commit a transaction.
+const maxCommitRetryBackoff = 5 * time.Second
+
+// maxCommitAttempts is the maximum number of attempts to commit a transaction
+// which fails with a retriable error.
+const maxCommitAttempts = 4
+
+// txnError is implemented by errors returned by transactional operations
+// (e.g. kafka.Error), classifying the error.  Any error that is neither fatal
+// nor retriable requires the transaction to be aborted.
+type txnError interface {
+	IsFatal() bool
+	IsRetriable() bool
+}
+
+// IsFatalError returns true if an error returned by a transactional operation
+// is fatal (e.g. the producer has been fenced by another producer with the
+// same transactional id).  A producer which has returned a fatal error can no
+// longer be used and must be closed.
+func IsFatalError(err error) bool {
+	var te txnError
+	return errors.As(err, &te) && te.IsFatal()
+}
+
+// isRetriableError returns true if a transactional operation which returned
+// an error may be retried.
+func isRetriableError(err error) bool {
+	var te txnError
+	return errors.As(err, &te) && te.IsRetriable()
+}
+
+// Transaction is a transaction of a transactional producer, passed to the
+// func called by producer.Transact.  Messages produced using the Transaction
+// are part of the transaction.
+//
+// A Transaction is closed when the func to which it was passed returns;
+// messages may not then be produced using the Transaction, which would
+// otherwise be part of whichever transaction is current.
+type Transaction struct {
+	mu       sync.RWMutex // read-locked while producing; locked to close
+	producer *producer
+	closed   bool
+}
+
+// Produce produces a message in the transaction (see producer.Produce).
+func (tx *Transaction) Produce(msg *kafka.Message) error {
+	tx.mu.RLock()
+	defer tx.mu.RUnlock()
+	if tx.closed {
+		return ErrTransactionClosed
+	}
+	return tx.producer.Produce(msg)
+}
+
+// MustProduce produces a message in the transaction and waits for a delivery
+// event (see producer.MustProduce).
+func (tx *Transaction) MustProduce(msg *kafka.Message) (*kafka.Message, error) {
+	return tx.MustProduceContext(context.Background(), msg)
+}
+
+// MustProduceContext produces a message in the transaction and waits for a
+// delivery event, until the context is done (see producer.MustProduceContext).
+func (tx *Transaction) MustProduceContext(ctx context.Context, msg *kafka.Message) (*kafka.Message, error) {
+	tx.mu.RLock()
+	defer tx.mu.RUnlock()
+	if tx.closed {
+		return nil, ErrTransactionClosed
+	}
+	return tx.producer.MustProduceContext(ctx, msg)
+}
+
+// ProduceAsync produces a message in the transaction without waiting for a
+// delivery event (see producer.ProduceAsync).  If the transaction is closed,
+// the DeliveryFuture is already done with ErrTransactionClosed.
+func (tx *Transaction) ProduceAsync(msg *kafka.Message) *DeliveryFuture {
+	tx.mu.RLock()
+	defer tx.mu.RUnlock()
+	if tx.closed {
+		return deliveredFuture(nil, ErrTransactionClosed)
+	}
+	return tx.producer.ProduceAsync(msg)
+}
+
+// ProduceBatch produces messages in the transaction and waits for the delivery
+// event for every message (see producer.ProduceBatch).
+func (tx *Transaction) ProduceBatch(ctx context.Context, msgs []*kafka.Message) BatchResult {
+	return produceBatch(ctx, msgs, tx.producer.prepare, tx.produceAsync, tx.producer.Flush)
+}
+
+// produceAsync produces a message to which any middleware has been applied in
+// the transaction (see producer.produceAsync).  If the transaction is closed,
+// the DeliveryFuture is already done with ErrTransactionClosed.
+func (tx *Transaction) produceAsync(msg *kafka.Message) *DeliveryFuture {
+	tx.mu.RLock()
+	defer tx.mu.RUnlock()
+	if tx.closed {
+		return deliveredFuture(nil, ErrTransactionClosed)
+	}
+	return tx.producer.produceAsync(msg)
+}
+
+// SendOffsets adds the offsets of messages consumed by a consumer in a
+// consumer group to the transaction, so that the offsets are committed only
+// if the transaction is committed.  The offsets are the offsets of the next
+// messages to be consumed, as for a commit by the consumer.
+//
+// The consumer group metadata is obtained from the consumer (see
+// kafka.Consumer.GetConsumerGroupMetadata) and identifies the generation of
+// the group: offsets sent with the metadata of a consumer which is no longer
+// a member of the group, or whose partitions have since been reassigned, are
+// rejected.
+func (tx *Transaction) SendOffsets(ctx context.Context, offsets []kafka.TopicPartition, metadata *kafka.ConsumerGroupMetadata) error {
+	tx.mu.RLock()
+	defer tx.mu.RUnlock()
+	if tx.closed {
+		return ErrTransactionClosed
+	}
+	return tx.producer.hooks.SendOffsetsToTransaction(tx.producer.producer, ctx, offsets, metadata)
+}
+
+// close closes the transaction, waiting for any message being produced using
+// the transaction.
+func (tx *Transaction) close() {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+	tx.closed = true
+}
+
+// BeginTransaction begins a transaction.  The producer must have a
+// transactional id (see WithTransactionalId).
+func (p *producer) BeginTransaction() error {
+	return p.hooks.BeginTransaction(p.producer)
+}
+
+// CommitTransaction commits the current transaction, waiting for any messages
+// in the transaction to be delivered.  If the transaction cannot be committed
+// and must be aborted, AbortTransaction must be called before another
+// transaction is begun.
+func (p *producer) CommitTransaction(ctx context.Context) error {
+	return p.hooks.CommitTransaction(p.producer, ctx)
+}
+
+// AbortTransaction aborts the current transaction.  Messages produced in the
+// transaction are not delivered to consumers reading committed messages.
+func (p *producer) AbortTransaction(ctx context.Context) error {
+	return p.hooks.AbortTransaction(p.producer, ctx)
+}
+
+// Transact calls a func in a transaction.  The transaction is committed if the
+// func returns nil, otherwise it is aborted and the error returned.  If the
+// func panics the transaction is aborted and the panic re-raised.
+//
+// If the commit fails with an error that may be retried, the commit is retried
+// after a delay, doubling with each attempt, until it has been attempted
+// maxCommitAttempts times or the context is done.  If the transaction cannot
+// be committed it is aborted and the error from the commit returned.  If the
+// error is fatal (see IsFatalError) the transaction is not aborted; the
+// producer must be closed.
+func (p *producer) Transact(ctx context.Context, fn func(*Transaction) error) error {
+	if err := p.BeginTransaction(); err != nil {
+		return err
+	}
+
+	if err := p.call(ctx, fn); err != nil {
+		return p.abort(ctx, err)
+	}
+
+	delay := commitRetryBackoff
+	for attempt := 1; ; attempt++ {
+		err := p.CommitTransaction(ctx)
+		switch {
+		case err == nil:
+			return nil
+
+		case isRetriableError(err) && attempt < maxCommitAttempts && ctx.Err() == nil:
+			timer := time.NewTimer(delay)
+			select {
+			case <-timer.C:
+			case <-ctx.Done():
+				timer.Stop()
+				return p.abort(ctx, err)
+			}
+			if delay *= 2; delay > maxCommitRetryBackoff {
+				delay = maxCommitRetryBackoff
+			}
+
+		default:
+			return p.abort(ctx, err)
+		}
+	}
+}
+
+// call calls a func in the current transaction, aborting the transaction if
+// the func panics.  The Transaction passed to the func is closed when it
+// returns.
+func (p *producer) call(ctx context.Context, fn func(*Transaction) error) error {
+	tx := &Transaction{producer: p}
+	defer tx.close()
+	defer func() {
+		if r := recover(); r != nil {
+			p.AbortTransaction(ctx)
+			panic(r)
+		}
+	}()
+	return fn(tx)
+}
+
+// abort aborts the current transaction following an error, returning the
+// error (or a fatal error from the abort, if any).  A transaction cannot be
+// aborted following a fatal error.
+func (p *producer) abort(ctx context.Context, err error) error {
+	if IsFatalError(err) {
+		return err
+	}
+	if aerr := p.AbortTransaction(ctx); IsFatalError(aerr) {
+		return aerr
+	}
+	return err
+}
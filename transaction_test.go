@@ -0,0 +1,279 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+
+	"github.com/deltics/go-kafka/mock"
+)
+
+// transactionHooks returns producer hooks which record the transactional
+// operations called, in order.
+func transactionHooks(calls *[]string) mock.MockProducerProvider {
+	hk := mock.ProducerHooks()
+	hk.Funcs().InitTransactions = func(*kafka.Producer, context.Context) error {
+		*calls = append(*calls, "init")
+		return nil
+	}
+	hk.Funcs().BeginTransaction = func(*kafka.Producer) error {
+		*calls = append(*calls, "begin")
+		return nil
+	}
+	hk.Funcs().CommitTransaction = func(*kafka.Producer, context.Context) error {
+		*calls = append(*calls, "commit")
+		return nil
+	}
+	hk.Funcs().AbortTransaction = func(*kafka.Producer, context.Context) error {
+		*calls = append(*calls, "abort")
+		return nil
+	}
+	return hk
+}
+
+func TestThatATransactionalProducerInitialisesTransactions(t *testing.T) {
+	// ARRANGE
+	calls := []string{}
+	hk := transactionHooks(&calls)
+
+	// ACT
+	p, err := NewProducer(NewConfig().WithHooks(hk).WithTransactionalId("txn"))
+
+	// ASSERT
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if p == nil {
+		t.Error("no producer returned")
+	}
+	wanted := "[init]"
+	if got := fmt.Sprintf("%v", calls); got != wanted {
+		t.Errorf("wanted %s, got %s", wanted, got)
+	}
+}
+
+func TestThatTransactCommitsTheTransactionWhenTheFuncSucceeds(t *testing.T) {
+	// ARRANGE
+	calls := []string{}
+	hk := transactionHooks(&calls)
+	hk.Funcs().Produce = func(p *kafka.Producer, m *kafka.Message, c chan kafka.Event) error {
+		calls = append(calls, "produce")
+		return nil
+	}
+	p, _ := NewProducer(NewConfig().WithHooks(hk).WithTransactionalId("txn"))
+
+	// ACT
+	err := p.Transact(context.Background(), func(tx *Transaction) error {
+		return tx.Produce(StringMessage("topic", "value"))
+	})
+
+	// ASSERT
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	wanted := "[init begin produce commit]"
+	if got := fmt.Sprintf("%v", calls); got != wanted {
+		t.Errorf("wanted %s, got %s", wanted, got)
+	}
+}
+
+func TestThatTransactAbortsTheTransactionWhenTheFuncFails(t *testing.T) {
+	// ARRANGE
+	calls := []string{}
+	hk := transactionHooks(&calls)
+	p, _ := NewProducer(NewConfig().WithHooks(hk).WithTransactionalId("txn"))
+	fnErr := errors.New("failed")
+
+	// ACT
+	err := p.Transact(context.Background(), func(tx *Transaction) error {
+		return fnErr
+	})
+
+	// ASSERT
+	if err != fnErr {
+		t.Errorf("wanted %v, got %v", fnErr, err)
+	}
+	wanted := "[init begin abort]"
+	if got := fmt.Sprintf("%v", calls); got != wanted {
+		t.Errorf("wanted %s, got %s", wanted, got)
+	}
+}
+
+func TestThatTransactAbortsTheTransactionAndRepanicsWhenTheFuncPanics(t *testing.T) {
+	// ARRANGE
+	calls := []string{}
+	hk := transactionHooks(&calls)
+	p, _ := NewProducer(NewConfig().WithHooks(hk).WithTransactionalId("txn"))
+
+	// ACT
+	var recovered interface{}
+	func() {
+		defer func() { recovered = recover() }()
+		p.Transact(context.Background(), func(tx *Transaction) error {
+			panic("boom")
+		})
+	}()
+
+	// ASSERT
+	if recovered != "boom" {
+		t.Errorf("wanted panic %q, got %v", "boom", recovered)
+	}
+	wanted := "[init begin abort]"
+	if got := fmt.Sprintf("%v", calls); got != wanted {
+		t.Errorf("wanted %s, got %s", wanted, got)
+	}
+}
+
+func TestThatTransactRetriesACommitWithARetriableError(t *testing.T) {
+	// ARRANGE
+	calls := []string{}
+	hk := transactionHooks(&calls)
+	hk.Funcs().CommitTransaction = func(*kafka.Producer, context.Context) error {
+		calls = append(calls, "commit")
+		if len(calls) == 3 {
+			return mock.RetriableError()
+		}
+		return nil
+	}
+	p, _ := NewProducer(NewConfig().WithHooks(hk).WithTransactionalId("txn"))
+
+	// ACT
+	err := p.Transact(context.Background(), func(tx *Transaction) error { return nil })
+
+	// ASSERT
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	wanted := "[init begin commit commit]"
+	if got := fmt.Sprintf("%v", calls); got != wanted {
+		t.Errorf("wanted %s, got %s", wanted, got)
+	}
+}
+
+func TestThatTransactDelaysRetriesOfACommitUntilTheContextIsDone(t *testing.T) {
+	// ARRANGE
+	calls := []string{}
+	hk := transactionHooks(&calls)
+	hk.Funcs().CommitTransaction = func(*kafka.Producer, context.Context) error {
+		calls = append(calls, "commit")
+		return mock.RetriableError()
+	}
+	p, _ := NewProducer(NewConfig().WithHooks(hk).WithTransactionalId("txn"))
+	ctx, cancel := context.WithTimeout(context.Background(), 2*commitRetryBackoff)
+	defer cancel()
+
+	// ACT
+	err := p.Transact(ctx, func(tx *Transaction) error { return nil })
+
+	// ASSERT
+	if err == nil {
+		t.Error("wanted error, got nil")
+	}
+	// the commit is retried after 100ms; the next retry (after a further
+	// 200ms) is interrupted when the context is done
+	wanted := "[init begin commit commit abort]"
+	if got := fmt.Sprintf("%v", calls); got != wanted {
+		t.Errorf("wanted %s, got %s", wanted, got)
+	}
+}
+
+func TestThatTransactAbortsTheTransactionAfterTheMaximumNumberOfCommitAttempts(t *testing.T) {
+	// ARRANGE
+	calls := []string{}
+	hk := transactionHooks(&calls)
+	hk.Funcs().CommitTransaction = func(*kafka.Producer, context.Context) error {
+		calls = append(calls, "commit")
+		return mock.RetriableError()
+	}
+	p, _ := NewProducer(NewConfig().WithHooks(hk).WithTransactionalId("txn"))
+
+	// ACT
+	err := p.Transact(context.Background(), func(tx *Transaction) error { return nil })
+
+	// ASSERT
+	if !isRetriableError(err) {
+		t.Errorf("wanted retriable error, got %v", err)
+	}
+	wanted := "[init begin commit commit commit commit abort]"
+	if got := fmt.Sprintf("%v", calls); got != wanted {
+		t.Errorf("wanted %s, got %s", wanted, got)
+	}
+}
+
+func TestThatTransactAbortsTheTransactionWhenTheCommitRequiresAbort(t *testing.T) {
+	// ARRANGE
+	calls := []string{}
+	hk := transactionHooks(&calls)
+	hk.Funcs().CommitTransaction = func(*kafka.Producer, context.Context) error {
+		calls = append(calls, "commit")
+		return mock.AbortableError()
+	}
+	p, _ := NewProducer(NewConfig().WithHooks(hk).WithTransactionalId("txn"))
+
+	// ACT
+	err := p.Transact(context.Background(), func(tx *Transaction) error { return nil })
+
+	// ASSERT
+	if err == nil || IsFatalError(err) {
+		t.Errorf("wanted abortable error, got %v", err)
+	}
+	wanted := "[init begin commit abort]"
+	if got := fmt.Sprintf("%v", calls); got != wanted {
+		t.Errorf("wanted %s, got %s", wanted, got)
+	}
+}
+
+func TestThatTransactReturnsAFatalErrorWhenTheProducerIsFenced(t *testing.T) {
+	// ARRANGE
+	calls := []string{}
+	hk := transactionHooks(&calls)
+	hk.Funcs().CommitTransaction = func(*kafka.Producer, context.Context) error {
+		calls = append(calls, "commit")
+		return mock.FencedError()
+	}
+	p, _ := NewProducer(NewConfig().WithHooks(hk).WithTransactionalId("txn"))
+
+	// ACT
+	err := p.Transact(context.Background(), func(tx *Transaction) error { return nil })
+
+	// ASSERT
+	if !IsFatalError(err) {
+		t.Errorf("wanted fatal error, got %v", err)
+	}
+	wanted := "[init begin commit]"
+	if got := fmt.Sprintf("%v", calls); got != wanted {
+		t.Errorf("wanted %s, got %s", wanted, got)
+	}
+}
+
+func TestThatATransactionIsClosedWhenTheFuncReturns(t *testing.T) {
+	// ARRANGE
+	calls := []string{}
+	hk := transactionHooks(&calls)
+	hk.Funcs().Produce = func(*kafka.Producer, *kafka.Message, chan kafka.Event) error {
+		calls = append(calls, "produce")
+		return nil
+	}
+	p, _ := NewProducer(NewConfig().WithHooks(hk).WithTransactionalId("txn"))
+
+	var tx *Transaction
+	p.Transact(context.Background(), func(t *Transaction) error {
+		tx = t
+		return nil
+	})
+
+	// ACT
+	err := tx.Produce(StringMessage("topic", "message"))
+
+	// ASSERT
+	if !errors.Is(err, ErrTransactionClosed) {
+		t.Errorf("wanted %v, got %v", ErrTransactionClosed, err)
+	}
+	wanted := "[init begin commit]"
+	if got := fmt.Sprintf("%v", calls); got != wanted {
+		t.Errorf("wanted %s, got %s", wanted, got)
+	}
+}
@@ -0,0 +1,68 @@
+package kafka
+
+import (
+	"context"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+)
+
+// TypedMessageHandler is called with the decoded value of a message received
+// on a topic, together with the message itself (e.g. for its key, headers or
+// timestamp).
+type TypedMessageHandler[T any] func(context.Context, T, *kafka.Message) error
+
+// WithTypedHandler returns a Config with a TypedMessageHandler for the
+// specified topic (or topic pattern; see WithMessageHandler).  The value of each
+// message is decoded by the specified Deserializer before the handler is called.
+//
+// If a message cannot be decoded the handler is not called; the message fails
+// with an ErrDeserializationFailed, subject to the policy set by the
+// OnDeserializationFailure option (see OnDeserializationFailure).
+//
+// Since a method cannot have type parameters, this is a function rather than a
+// method of the Config:
+//
+//	cfg = kafka.WithTypedHandler(cfg, "orders", handler, kafka.JSONDeserializer[Order]())
+func WithTypedHandler[T any](c *config, t string, fn TypedMessageHandler[T], d Deserializer[T], opts ...HandlerOption) *config {
+	return c.WithMessageHandler(t, typedHandler(fn, d), opts...)
+}
+
+// typedHandler returns a MessageHandler which decodes the value of a message
+// before calling a TypedMessageHandler.
+func typedHandler[T any](fn TypedMessageHandler[T], d Deserializer[T]) MessageHandler {
+	return func(ctx context.Context, msg *kafka.Message) error {
+		v, err := d(*msg.TopicPartition.Topic, msg.Value)
+		if err != nil {
+			return ErrDeserializationFailed{TopicPartition: msg.TopicPartition, Err: err}
+		}
+		return fn(ctx, v, msg)
+	}
+}
+
+// OnDeserializationFailure returns a HandlerOption which sets the
+// FailurePolicy for messages on a topic that cannot be decoded by the
+// Deserializer of a TypedMessageHandler.  The FailurePolicy for the topic (or
+// config) applies only to failures of the handler itself.
+//
+// If no policy is set, messages that cannot be decoded are sent to the
+// dead-letter (if configured) or otherwise skipped.  Since a message that
+// cannot be decoded will never be decoded, such messages are not forwarded to
+// any retry topics for the handler.
+func OnDeserializationFailure(policy FailurePolicy) HandlerOption {
+	return func(h *messageHandler) {
+		h.deserializationPolicy = policy
+	}
+}
+
+// deserializationPolicy returns the FailurePolicy for messages that cannot be
+// decoded for a handler.
+func (c *Consumer) deserializationPolicy(h messageHandler) FailurePolicy {
+	switch {
+	case h.deserializationPolicy != nil:
+		return h.deserializationPolicy
+	case c.deadLetter != nil:
+		return DeadLetterFailedMessages()
+	default:
+		return SkipFailedMessages()
+	}
+}
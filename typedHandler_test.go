@@ -0,0 +1,108 @@
+package kafka
+
+import (
+	"context"
+	"testing"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+
+	"github.com/deltics/go-kafka/mock"
+)
+
+type typedOrder struct {
+	Id string `json:"id"`
+}
+
+func TestThatATypedHandlerIsCalledWithTheDecodedValue(t *testing.T) {
+	// MOCK
+	p := mock.ConsumerHooks()
+	p.Messages([]interface{}{
+		StringMessage("orders", `{"id":"order-1"}`),
+	})
+
+	// ARRANGE
+	var got typedOrder
+	var gotMsg *kafka.Message
+	cfg := WithTypedHandler(NewConfig().WithHooks(p), "orders", func(ctx context.Context, o typedOrder, msg *kafka.Message) error {
+		got = o
+		gotMsg = msg
+		return nil
+	}, JSONDeserializer[typedOrder]())
+
+	c, _ := NewConsumer(cfg)
+
+	// ACT
+	c.Run(context.Background())
+
+	// ASSERT
+	if got.Id != "order-1" {
+		t.Errorf("wanted order %q, got %q", "order-1", got.Id)
+	}
+	if gotMsg == nil {
+		t.Error("wanted message, got nil")
+	}
+}
+
+func TestThatAMessageThatCannotBeDecodedIsSentToTheDeadLetterAndNotTheHandler(t *testing.T) {
+	// MOCK
+	p := mock.ConsumerHooks()
+	p.Messages([]interface{}{
+		StringMessage("orders", `not json`),
+	})
+
+	// ARRANGE
+	called := false
+	var failure *Failure
+	cfg := NewConfig().WithHooks(p).
+		WithFailurePolicy(StopOnFailure()).
+		WithDeadLetterFunc(func(ctx context.Context, f *Failure) error {
+			failure = f
+			return nil
+		})
+	cfg = WithTypedHandler(cfg, "orders", func(ctx context.Context, o typedOrder, msg *kafka.Message) error {
+		called = true
+		return nil
+	}, JSONDeserializer[typedOrder]())
+
+	c, _ := NewConsumer(cfg)
+
+	// ACT
+	err := c.Run(context.Background())
+
+	// ASSERT
+	if called {
+		t.Error("handler was called")
+	}
+	if _, ok := err.(ErrHandlerFailed); ok {
+		t.Errorf("wanted consumer not stopped by failure policy, got %v", err)
+	}
+	if failure == nil {
+		t.Fatal("wanted message sent to dead-letter")
+	}
+	if _, ok := failure.Err.(ErrDeserializationFailed); !ok {
+		t.Errorf("wanted %T, got %v", ErrDeserializationFailed{}, failure.Err)
+	}
+}
+
+func TestThatTheDeserializationFailurePolicyIsAppliedToMessagesThatCannotBeDecoded(t *testing.T) {
+	// MOCK
+	p := mock.ConsumerHooks()
+	p.Messages([]interface{}{
+		StringMessage("orders", `not json`),
+	})
+
+	// ARRANGE
+	cfg := WithTypedHandler(NewConfig().WithHooks(p), "orders", func(ctx context.Context, o typedOrder, msg *kafka.Message) error {
+		return nil
+	}, JSONDeserializer[typedOrder](), OnDeserializationFailure(StopOnFailure()))
+
+	c, _ := NewConsumer(cfg)
+
+	// ACT
+	err := c.Run(context.Background())
+
+	// ASSERT
+	if _, ok := err.(ErrHandlerFailed); !ok {
+		t.Errorf("wanted %T, got %v", ErrHandlerFailed{}, err)
+	}
+}
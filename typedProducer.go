@@ -0,0 +1,98 @@
+package kafka
+
+import (
+	"context"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+)
+
+// TypedProducer produces messages with keys of type K and values of type V,
+// encoded by the key and value Serializers of the producer.
+type TypedProducer[K, V any] struct {
+	producer *producer
+	key      Serializer[K]
+	value    Serializer[V]
+}
+
+// NewTypedProducer returns a TypedProducer using the specified Config and key
+// and value Serializers.
+//
+// e.g.
+//
+//	p, err := kafka.NewTypedProducer(cfg, kafka.StringSerializer(), kafka.JSONSerializer[Order]())
+func NewTypedProducer[K, V any](cfg *config, key Serializer[K], value Serializer[V]) (*TypedProducer[K, V], error) {
+	p, err := NewProducer(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &TypedProducer[K, V]{producer: p, key: key, value: value}, nil
+}
+
+func (p *TypedProducer[K, V]) Close() {
+	p.producer.Close()
+}
+
+func (p *TypedProducer[K, V]) Flush(timeoutMs int) int {
+	return p.producer.Flush(timeoutMs)
+}
+
+func (p *TypedProducer[K, V]) FlushAll() {
+	p.producer.FlushAll()
+}
+
+// message returns a message for the specified topic with the encoded key and
+// value and any headers.
+func (p *TypedProducer[K, V]) message(topic string, key K, value V, headers []kafka.Header) (*kafka.Message, error) {
+	k, err := p.key(topic, key)
+	if err != nil {
+		return nil, ErrSerializationFailed{Topic: topic, Field: "key", Err: err}
+	}
+	v, err := p.value(topic, value)
+	if err != nil {
+		return nil, ErrSerializationFailed{Topic: topic, Field: "value", Err: err}
+	}
+
+	return &kafka.Message{
+		TopicPartition: kafka.TopicPartition{Topic: &topic, Partition: kafka.PartitionAny},
+		Key:            k,
+		Value:          v,
+		Headers:        headers,
+	}, nil
+}
+
+// Produce produces a message with the specified key, value and headers to a
+// topic.  Delivery events are received over the producer.EventChannel (see
+// producer.Produce).
+//
+// If the key or value cannot be encoded, an ErrSerializationFailed is returned
+// and no message is produced.  If the context is done, the context error is
+// returned and no message is produced.
+func (p *TypedProducer[K, V]) Produce(ctx context.Context, topic string, key K, value V, headers ...kafka.Header) error {
+	msg, err := p.message(topic, key, value, headers)
+	if err != nil {
+		return err
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return p.producer.Produce(msg)
+}
+
+// MustProduce produces a message with the specified key, value and headers to a
+// topic and waits for a delivery event, until the context is done (see
+// producer.MustProduceContext).  The produced message is returned if
+// successful, otherwise an error is returned.
+//
+// If the key or value cannot be encoded, an ErrSerializationFailed is returned
+// and no message is produced.  If the context is already done, the context
+// error is returned and no message is produced.
+func (p *TypedProducer[K, V]) MustProduce(ctx context.Context, topic string, key K, value V, headers ...kafka.Header) (*kafka.Message, error) {
+	msg, err := p.message(topic, key, value, headers)
+	if err != nil {
+		return nil, err
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return p.producer.MustProduceContext(ctx, msg)
+}
@@ -0,0 +1,129 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+
+	"github.com/deltics/go-kafka/mock"
+)
+
+func TestThatTypedProducerProducesEncodedKeyAndValue(t *testing.T) {
+	// ARRANGE
+	var produced *kafka.Message
+	hk := mock.ProducerHooks()
+	hk.Funcs().Produce = func(p *kafka.Producer, m *kafka.Message, c chan kafka.Event) error {
+		produced = m
+		return nil
+	}
+
+	type order struct {
+		Id string `json:"id"`
+	}
+	p, _ := NewTypedProducer(NewConfig().WithHooks(hk), StringSerializer(), JSONSerializer[order]())
+
+	// ACT
+	err := p.Produce(context.Background(), "orders", "key", order{Id: "a"}, kafka.Header{Key: "h", Value: []byte("v")})
+
+	// ASSERT
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if produced == nil {
+		t.Fatal("no message produced")
+	}
+	if *produced.TopicPartition.Topic != "orders" {
+		t.Errorf("wanted topic %q, got %q", "orders", *produced.TopicPartition.Topic)
+	}
+	if string(produced.Key) != "key" {
+		t.Errorf("wanted key %q, got %q", "key", produced.Key)
+	}
+	if string(produced.Value) != `{"id":"a"}` {
+		t.Errorf("wanted value %s, got %s", `{"id":"a"}`, produced.Value)
+	}
+	if len(produced.Headers) != 1 || produced.Headers[0].Key != "h" {
+		t.Errorf("wanted header %q, got %v", "h", produced.Headers)
+	}
+}
+
+func TestThatTypedProducerMustProduceReturnsTheDeliveredMessage(t *testing.T) {
+	// ARRANGE
+	hk := mock.ProducerHooks()
+	hk.Funcs().Produce = func(p *kafka.Producer, m *kafka.Message, c chan kafka.Event) error {
+		go func() {
+			m.TopicPartition.Offset = 1
+			c <- m
+		}()
+		return nil
+	}
+
+	p, _ := NewTypedProducer(NewConfig().WithHooks(hk), StringSerializer(), StringSerializer())
+
+	// ACT
+	got, err := p.MustProduce(context.Background(), "orders", "key", "value")
+
+	// ASSERT
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if got == nil || got.TopicPartition.Offset != 1 {
+		t.Errorf("wanted delivered message, got %v", got)
+	}
+}
+
+func TestThatTypedProducerReturnsSerializationErrorsWithoutProducing(t *testing.T) {
+	// ARRANGE
+	produced := false
+	hk := mock.ProducerHooks()
+	hk.Funcs().Produce = func(p *kafka.Producer, m *kafka.Message, c chan kafka.Event) error {
+		produced = true
+		return nil
+	}
+
+	serErr := errors.New("cannot encode")
+	value := func(string, string) ([]byte, error) { return nil, serErr }
+	p, _ := NewTypedProducer(NewConfig().WithHooks(hk), StringSerializer(), value)
+
+	// ACT
+	err := p.Produce(context.Background(), "orders", "key", "value")
+
+	// ASSERT
+	var sf ErrSerializationFailed
+	if !errors.As(err, &sf) || sf.Field != "value" {
+		t.Errorf("wanted %T for value, got %v", ErrSerializationFailed{}, err)
+	}
+	if !errors.Is(err, serErr) {
+		t.Errorf("wanted error wrapping %v, got %v", serErr, err)
+	}
+	if produced {
+		t.Error("message was produced")
+	}
+}
+
+func TestThatTypedProducerDoesNotProduceWhenTheContextIsDone(t *testing.T) {
+	// ARRANGE
+	produced := false
+	hk := mock.ProducerHooks()
+	hk.Funcs().Produce = func(p *kafka.Producer, m *kafka.Message, c chan kafka.Event) error {
+		produced = true
+		return nil
+	}
+
+	p, _ := NewTypedProducer(NewConfig().WithHooks(hk), StringSerializer(), StringSerializer())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	// ACT
+	err := p.Produce(ctx, "orders", "key", "value")
+
+	// ASSERT
+	if err != context.Canceled {
+		t.Errorf("wanted %v, got %v", context.Canceled, err)
+	}
+	if produced {
+		t.Error("message was produced")
+	}
+}
@@ -0,0 +1,163 @@
+package kafka
+
+import (
+	"hash"
+	"hash/fnv"
+	"sync"
+
+	"github.com/confluentinc/confluent-kafka-go/kafka"
+)
+
+// workerQueueSize is the number of messages that may be queued for each
+// worker in a workerPool.
+const workerQueueSize = 64
+
+// job is a message (or batch of messages) to be handled by a worker.
+type job struct {
+	route route
+	msgs  []*kafka.Message
+}
+
+// result is the outcome of a job.  err is the error returned by the handler
+// (after applying any FailurePolicy); a non-nil err stops the consumer.
+type result struct {
+	msgs []*kafka.Message
+	err  error
+}
+
+// workerPool is a pool of goroutines handling messages concurrently.  Each
+// message is dispatched to a worker determined by its shard; messages with the
+// same shard are handled by the same worker, in the order dispatched.
+type workerPool struct {
+	queues  []chan job
+	results chan result
+	quit    chan struct{}
+	wg      sync.WaitGroup
+}
+
+func newWorkerPool(n int, fn func(job) error) *workerPool {
+	p := &workerPool{
+		queues:  make([]chan job, n),
+		results: make(chan result, n*workerQueueSize),
+		quit:    make(chan struct{}),
+	}
+
+	p.wg.Add(n)
+	for i := range p.queues {
+		p.queues[i] = make(chan job, workerQueueSize)
+		go p.work(p.queues[i], fn)
+	}
+
+	return p
+}
+
+// work handles the jobs on a queue until the queue is closed.  Once the pool
+// is stopping or a job has failed, any remaining jobs are discarded.
+func (p *workerPool) work(q chan job, fn func(job) error) {
+	defer p.wg.Done()
+
+	failed := false
+	for j := range q {
+		if failed {
+			continue
+		}
+		select {
+		case <-p.quit:
+			continue
+		default:
+		}
+
+		err := fn(j)
+		p.results <- result{msgs: j.msgs, err: err}
+
+		failed = err != nil
+	}
+}
+
+// dispatch queues a job for the worker for the specified shard.  If the queue
+// is full, results are passed to the specified func while waiting; if the func
+// returns an error, the job is not dispatched and the error returned.
+func (p *workerPool) dispatch(j job, shard uint32, fn func(result) error) error {
+	q := p.queues[shard%uint32(len(p.queues))]
+	for {
+		select {
+		case q <- j:
+			return nil
+		case r := <-p.results:
+			if err := fn(r); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// collect passes any available results to the specified func, without
+// waiting.  If the func returns an error, the error is returned.
+func (p *workerPool) collect(fn func(result) error) error {
+	for {
+		select {
+		case r := <-p.results:
+			if err := fn(r); err != nil {
+				return err
+			}
+		default:
+			return nil
+		}
+	}
+}
+
+// await waits for the next result and passes it to the specified func,
+// returning any error returned by the func.
+func (p *workerPool) await(fn func(result) error) error {
+	return fn(<-p.results)
+}
+
+// stop stops the pool.  Any jobs that have not been started are discarded;
+// jobs already in progress are allowed to complete, with their results passed
+// to the specified func.  The first error returned by the func is returned.
+func (p *workerPool) stop(fn func(result) error) error {
+	close(p.quit)
+	for _, q := range p.queues {
+		close(q)
+	}
+
+	go func() {
+		p.wg.Wait()
+		close(p.results)
+	}()
+
+	var err error
+	for r := range p.results {
+		if rerr := fn(r); err == nil {
+			err = rerr
+		}
+	}
+	return err
+}
+
+// partitionShard returns the shard for a message such that all messages on
+// the same partition share the same shard.
+func partitionShard(msg *kafka.Message) uint32 {
+	return shardHash(msg).Sum32()
+}
+
+// keyShard returns the shard for a message such that all messages with the
+// same key on the same partition share the same shard.
+func keyShard(msg *kafka.Message) uint32 {
+	h := shardHash(msg)
+	h.Write(msg.Key)
+	return h.Sum32()
+}
+
+// shardHash returns a hash of the topic and partition of a message.
+func shardHash(msg *kafka.Message) hash.Hash32 {
+	h := fnv.New32a()
+	h.Write([]byte(*msg.TopicPartition.Topic))
+	h.Write([]byte{
+		byte(msg.TopicPartition.Partition >> 24),
+		byte(msg.TopicPartition.Partition >> 16),
+		byte(msg.TopicPartition.Partition >> 8),
+		byte(msg.TopicPartition.Partition),
+	})
+	return h
+}